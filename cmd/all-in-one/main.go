@@ -4,12 +4,20 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"log"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/app"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
+	grpchandler "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/grpc"
+	handlerhttp "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/http"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/http/middleware"
 	"google.golang.org/grpc"
 )
 
@@ -17,44 +25,84 @@ func main() {
 	configFlag := flag.String("config", "", "path to the config file")
 	flag.Parse()
 
-	c, _ := config.LoadConfig(*configFlag)
+	c, err := config.LoadConfig(*configFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := app.Migrate(context.Background(), c, []string{"users", "plans", "subscriptions", "payments"}, flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	mux := http.NewServeMux()
 
 	// starts the gRPC server
 	lis, _ := net.Listen("tcp", c.Server.Endpoint.GRPC)
-	var opts []grpc.ServerOption
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(grpchandler.UnaryRequestContext)}
 	grpcServer := grpc.NewServer(opts...)
 
+	var shutdowns []func() error
+	checks := map[string]handlerhttp.HealthCheck{}
+
 	{
-		a := app.NewUser(&c.Users)
+		a, err := app.NewUser(&c.Users)
+		if err != nil {
+			log.Fatal(err)
+		}
 		a.RegisterRoutes(mux)
+		checks["users"] = a.Check
+		shutdowns = append(shutdowns, a.Shutdown)
 	}
 
 	{
-		a := app.NewPlan(&c.Plans)
+		a, err := app.NewPlan(&c.Plans)
+		if err != nil {
+			log.Fatal(err)
+		}
 		a.RegisterRoutes(mux, grpcServer)
+		checks["plans"] = a.Check
+		shutdowns = append(shutdowns, a.Shutdown)
 	}
 
 	{
 		a, err := app.NewPayment(&c.Payments)
 		if err != nil {
-			panic(err)
+			log.Fatal(err)
 		}
 		a.RegisterRoutes(mux)
-		defer func() {
-			_ = a.Shutdown()
-		}()
+		checks["payments"] = a.Check
+		shutdowns = append(shutdowns, a.Shutdown)
 	}
 
 	{
-		a := app.NewSubscription(&c.Subscriptions)
+		a, err := app.NewSubscription(&c.Subscriptions)
+		if err != nil {
+			log.Fatal(err)
+		}
 		a.RegisterRoutes(mux)
+		checks["subscriptions"] = a.Check
+		shutdowns = append(shutdowns, a.Shutdown)
 	}
 
+	mux.Handle("GET /healthz", handlerhttp.NewHealthHandler(checks))
+
 	go func() {
 		_ = grpcServer.Serve(lis)
 	}()
 
-	_ = http.ListenAndServe(c.Server.Endpoint.HTTP, mux)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := app.ListenAndServe(ctx, c.Server.Endpoint.HTTP, middleware.Default(mux)); err != nil {
+		log.Print(err)
+	}
+	grpcServer.GracefulStop()
+	for _, shutdown := range shutdowns {
+		if err := shutdown(); err != nil {
+			log.Print(err)
+		}
+	}
 }
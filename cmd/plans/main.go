@@ -4,12 +4,20 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"log"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/app"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
+	grpchandler "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/grpc"
+	handlerhttp "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/http"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/http/middleware"
 	"google.golang.org/grpc"
 )
 
@@ -17,19 +25,42 @@ func main() {
 	configFlag := flag.String("config", "", "path to the config file")
 	flag.Parse()
 
-	c, _ := config.LoadConfig(*configFlag)
+	c, err := config.LoadConfig(*configFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := app.Migrate(context.Background(), c, []string{"plans"}, flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	// starts the gRPC server
 	lis, _ := net.Listen("tcp", c.Server.Endpoint.GRPC)
-	var opts []grpc.ServerOption
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(grpchandler.UnaryRequestContext)}
 	grpcServer := grpc.NewServer(opts...)
 
-	a := app.NewPlan(&c.Plans)
+	a, err := app.NewPlan(&c.Plans)
+	if err != nil {
+		log.Fatal(err)
+	}
 	a.RegisterRoutes(http.DefaultServeMux, grpcServer)
+	http.Handle("GET /healthz", handlerhttp.NewHealthHandler(map[string]handlerhttp.HealthCheck{"plans": a.Check}))
 
 	go func() {
 		_ = grpcServer.Serve(lis)
 	}()
 
-	_ = http.ListenAndServe(c.Server.Endpoint.HTTP, http.DefaultServeMux)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := app.ListenAndServe(ctx, c.Server.Endpoint.HTTP, middleware.Default(http.DefaultServeMux)); err != nil {
+		log.Print(err)
+	}
+	grpcServer.GracefulStop()
+	if err := a.Shutdown(); err != nil {
+		log.Print(err)
+	}
 }
@@ -4,19 +4,48 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/app"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
+	handlerhttp "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/http"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/http/middleware"
 )
 
 func main() {
 	configFlag := flag.String("config", "", "path to the config file")
 	flag.Parse()
 
-	c, _ := config.LoadConfig(*configFlag)
-	a := app.NewSubscription(&c.Subscriptions)
+	c, err := config.LoadConfig(*configFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := app.Migrate(context.Background(), c, []string{"subscriptions"}, flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	a, err := app.NewSubscription(&c.Subscriptions)
+	if err != nil {
+		log.Fatal(err)
+	}
 	a.RegisterRoutes(http.DefaultServeMux)
-	_ = http.ListenAndServe(c.Server.Endpoint.HTTP, http.DefaultServeMux)
+	http.Handle("GET /healthz", handlerhttp.NewHealthHandler(map[string]handlerhttp.HealthCheck{"subscriptions": a.Check}))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := app.ListenAndServe(ctx, c.Server.Endpoint.HTTP, middleware.Default(http.DefaultServeMux)); err != nil {
+		log.Print(err)
+	}
+	if err := a.Shutdown(); err != nil {
+		log.Print(err)
+	}
 }
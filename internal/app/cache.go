@@ -0,0 +1,20 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"log/slog"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/cache"
+)
+
+func cacheOptions(cfg config.Cache) cache.Options {
+	return cache.Options{TTL: cfg.TTL, Size: cfg.Size}
+}
+
+// logCacheStats logs the counters of the cache of a service on shutdown
+func logCacheStats(service string, stats cache.Stats) {
+	slog.Info("cache stats", "service", service, "hits", stats.Hits, "misses", stats.Misses, "evictions", stats.Evictions)
+}
@@ -0,0 +1,60 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
+	storegorm "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/gorm"
+	"gorm.io/gorm"
+)
+
+// openDB opens the database described by cfg and brings its schema up to date with the given migrations.
+// When automatic migrations are disabled, it fails instead if any of the migrations is pending.
+func openDB(cfg config.Database, store string, migrations []storegorm.Migration) (*gorm.DB, error) {
+	db, m, err := openMigrator(cfg, store, migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	if cfg.AutoMigrate {
+		_, err = m.Up(ctx)
+	} else {
+		err = m.Check(ctx)
+	}
+	if err != nil {
+		_ = storegorm.Close(db)
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// openMigrator opens the database described by cfg, without touching its schema
+func openMigrator(cfg config.Database, store string, migrations []storegorm.Migration) (*gorm.DB, *storegorm.Migrator, error) {
+	db, err := storegorm.Open(cfg.Driver, cfg.DSN, storegorm.Pool{
+		MaxOpenConns:    cfg.MaxOpenConns,
+		MaxIdleConns:    cfg.MaxIdleConns,
+		ConnMaxLifetime: cfg.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := storegorm.NewMigrator(db, store, migrations)
+	if err != nil {
+		_ = storegorm.Close(db)
+		return nil, nil, err
+	}
+
+	return db, m, nil
+}
+
+// usesDatabase tells whether a service keeping its data in the given store needs a database
+func usesDatabase(store string) bool {
+	return store == config.StoreDatabase || store == config.StoreSQLite
+}
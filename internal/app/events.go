@@ -0,0 +1,57 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/outbox"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// events publishes the events of a service, written to its outbox
+type events struct {
+	nc    *nats.Conn
+	relay *outbox.Relay
+}
+
+// startEvents connects to NATS and starts relaying the messages of ob, when events are configured. It returns
+// nil otherwise.
+func startEvents(cfg config.Events, ob store.Outbox) (*events, error) {
+	if cfg.Subject == "" {
+		return nil, nil
+	}
+
+	nc, err := nats.Connect(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	relay := outbox.NewRelay(ob, outbox.NewJetStreamPublisher(js), outbox.Options{})
+	relay.Start()
+	return &events{nc: nc, relay: relay}, nil
+}
+
+// check reports whether NATS can be reached
+func (e *events) check() error {
+	if status := e.nc.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("NATS connection is %s", status)
+	}
+	return nil
+}
+
+// Close publishes the events still in the outbox and closes the connection to NATS
+func (e *events) Close() error {
+	errRelay := e.relay.Close()
+	return errors.Join(errRelay, e.nc.Drain())
+}
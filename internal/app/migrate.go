@@ -0,0 +1,128 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
+	storegorm "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/gorm"
+)
+
+// database is the SQL database of a service, along with the migrations of its store
+type database struct {
+	service    string
+	cfg        config.Database
+	migrations []storegorm.Migration
+}
+
+// databases returns the SQL databases used by the given services. Services keeping their data in memory
+// have no database.
+func databases(c *config.Config, services ...string) ([]database, error) {
+	var ret []database
+	for _, service := range services {
+		switch service {
+		case "users":
+			if usesDatabase(c.Users.Store) {
+				ret = append(ret, database{service, c.Users.Database, storegorm.UserMigrations})
+			}
+		case "plans":
+			if usesDatabase(c.Plans.Store) {
+				ret = append(ret, database{service, c.Plans.Database, storegorm.PlanMigrations})
+			}
+		case "subscriptions":
+			if usesDatabase(c.Subscriptions.Store) {
+				ret = append(ret, database{service, c.Subscriptions.Database, storegorm.SubscriptionMigrations})
+			}
+		case "payments":
+			ret = append(ret, database{service, c.Payments.Database, storegorm.PaymentMigrations})
+		default:
+			return nil, fmt.Errorf("unknown service %q", service)
+		}
+	}
+	return ret, nil
+}
+
+// Migrate runs the migrate command against the databases of the given services. The command is one of
+// "up", which applies all pending migrations, "down [steps]", which reverts the last steps migrations
+// (one by default), or "status", which lists the migrations and when they were applied.
+func Migrate(ctx context.Context, c *config.Config, services []string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [steps]|status")
+	}
+
+	steps := 1
+	switch args[0] {
+	case "up", "status":
+	case "down":
+		if len(args) > 1 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+
+	dbs, err := databases(c, services...)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range dbs {
+		db, m, err := openMigrator(d.cfg, d.service, d.migrations)
+		if err != nil {
+			return err
+		}
+		defer storegorm.Close(db)
+
+		switch args[0] {
+		case "up":
+			applied, err := m.Up(ctx)
+			for _, migration := range applied {
+				fmt.Fprintf(out, "%s: applied %d (%s)\n", d.service, migration.Version, migration.Name)
+			}
+			if err != nil {
+				return err
+			}
+
+		case "down":
+			reverted, err := m.Down(ctx, steps)
+			for _, migration := range reverted {
+				fmt.Fprintf(out, "%s: reverted %d (%s)\n", d.service, migration.Version, migration.Name)
+			}
+			if err != nil {
+				return err
+			}
+
+		case "status":
+			status, err := m.Status(ctx)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SERVICE\tVERSION\tNAME\tAPPLIED AT")
+			for _, s := range status {
+				appliedAt := "pending"
+				if s.AppliedAt != nil {
+					appliedAt = s.AppliedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", d.service, s.Version, s.Name, appliedAt)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
+	storegorm "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	c := &config.Config{
+		Plans: config.Plans{
+			Store:    config.StoreDatabase,
+			Database: config.Database{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "plans.db")},
+		},
+	}
+	services := []string{"users", "plans"}
+
+	// test
+	out := &bytes.Buffer{}
+	require.NoError(t, Migrate(ctx, c, services, []string{"up"}, out))
+
+	// verify
+	assert.Contains(t, out.String(), "plans: applied 1 (create plans)")
+	assert.Contains(t, out.String(), "plans: applied 2 (index plans)")
+	assert.Contains(t, out.String(), "plans: applied 3 (create plans audit)")
+	assert.NotContains(t, out.String(), "users")
+	plan, err := NewPlan(&c.Plans)
+	require.NoError(t, err)
+	require.NoError(t, plan.Shutdown())
+
+	// test: revert one step
+	out.Reset()
+	require.NoError(t, Migrate(ctx, c, services, []string{"down"}, out))
+	assert.Equal(t, "plans: reverted 6 (delete sent messages of plans outbox)\n", out.String())
+	_, err = NewPlan(&c.Plans)
+	assert.ErrorIs(t, err, storegorm.ErrSchemaBehind)
+
+	// test: status
+	out.Reset()
+	require.NoError(t, Migrate(ctx, c, services, []string{"status"}, out))
+	assert.Contains(t, out.String(), "create plans")
+	assert.Regexp(t, `plans\s+6\s+delete sent messages of plans outbox\s+pending`, out.String())
+}
+
+func TestMigrate_InvalidArguments(t *testing.T) {
+	c := &config.Config{}
+	ctx := context.Background()
+
+	assert.Error(t, Migrate(ctx, c, []string{"plans"}, nil, &bytes.Buffer{}))
+	assert.Error(t, Migrate(ctx, c, []string{"plans"}, []string{"sideways"}, &bytes.Buffer{}))
+	assert.Error(t, Migrate(ctx, c, []string{"plans"}, []string{"down", "zero"}, &bytes.Buffer{}))
+	assert.Error(t, Migrate(ctx, c, []string{"invoices"}, []string{"up"}, &bytes.Buffer{}))
+}
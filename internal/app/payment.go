@@ -5,38 +5,63 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
 	planhttp "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/http"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/outbox"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	storeaudit "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/audit"
 	storegorm "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/gorm"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/retention"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 type Payment struct {
-	Handler  *planhttp.PaymentHandler
-	Store    store.Payment
+	Handler *planhttp.PaymentHandler
+	Bulk    *planhttp.BulkHandler[model.Payment]
+	Store   store.Payment
+	// Tx runs operations on Store atomically
+	Tx store.Transactor
+	// Audit has the trail of the changes made through Store
+	Audit store.Audit
+	// Outbox has the payments waiting to be published, along with the events announcing the changes made
+	// through Store, which are written with the changes
+	Outbox   store.Outbox
+	relay    *outbox.Relay
+	purge    *retention.Job
+	db       *gorm.DB
 	natsConn *nats.Conn
 	cctx     jetstream.ConsumeContext
 }
 
-func NewPayment(cfg *config.Payments) (*Payment, error) {
+func NewPayment(cfg *config.Payments) (_ *Payment, err error) {
 	ctx := context.Background()
-	db, err := gorm.Open(sqlite.Open(cfg.SQLLite.DSN))
+	db, err := openDB(cfg.Database, "payments", storegorm.PaymentMigrations)
 	if err != nil {
 		return nil, err
 	}
-	_ = db.AutoMigrate(&model.Payment{})
+	defer func() {
+		if err != nil {
+			_ = storegorm.Close(db)
+		}
+	}()
 
 	nc, err := nats.Connect(cfg.NATS.Endpoint)
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		if err != nil {
+			nc.Close()
+		}
+	}()
 
 	js, err := jetstream.New(nc)
 	if err != nil {
@@ -59,10 +84,26 @@ func NewPayment(cfg *config.Payments) (*Payment, error) {
 		return nil, err
 	}
 
-	store := storegorm.NewPaymentStore(db)
+	tx := storegorm.NewTransactor(db)
+	audit := storegorm.NewAuditStore(db, "payments_audit")
+	ob := storegorm.NewOutboxStore(db, "payments_outbox")
+	base := storegorm.NewPaymentStore(db)
+	var store store.Payment = storeaudit.NewPaymentStore(base, audit, tx)
+	if cfg.NATS.EventsSubject != "" {
+		if err := checkEventsSubject(stream.CachedInfo().Config.Subjects, cfg.NATS.EventsSubject); err != nil {
+			return nil, err
+		}
+		store = outbox.NewPaymentStore(store, ob, tx, cfg.NATS.EventsSubject)
+	}
 	pmt := &Payment{
-		Handler:  planhttp.NewPaymentHandler(store, js, cfg.NATS.Subject, cfg.SubscriptionsEndpoint),
+		Handler:  planhttp.NewPaymentHandler(store, ob, cfg.NATS.Subject, cfg.SubscriptionsEndpoint),
+		Bulk:     planhttp.NewPaymentBulkHandler(store, tx, cfg.SubscriptionsEndpoint),
 		Store:    store,
+		Tx:       tx,
+		Audit:    audit,
+		Outbox:   ob,
+		relay:    outbox.NewRelay(ob, outbox.NewJetStreamPublisher(js), outbox.Options{}),
+		db:       db,
 		natsConn: nc,
 	}
 
@@ -70,21 +111,82 @@ func NewPayment(cfg *config.Payments) (*Payment, error) {
 	if err != nil {
 		return nil, err
 	}
+	pmt.relay.Start()
+
+	var failed []retention.Rule
+	if cfg.Retention.Failed > 0 {
+		failed = append(failed, retention.Rule{Name: "failed", Age: cfg.Retention.Failed, Filters: map[string]string{"status": model.PaymentFailed}})
+	}
+	pmt.purge = startRetention("payment", cfg.Retention.Retention, base, tx, failed...)
 
 	return pmt, nil
 }
 
+// checkEventsSubject fails when the events published under subject would be delivered to the consumer of the
+// stream bound to streamSubjects
+func checkEventsSubject(streamSubjects []string, subject string) error {
+	for _, action := range []string{outbox.ActionCreated, outbox.ActionUpdated, outbox.ActionDeleted, outbox.ActionRestored} {
+		event := subject + ".payment." + action
+		for _, bound := range streamSubjects {
+			if subjectMatches(bound, event) {
+				return fmt.Errorf("payment events on %q would be consumed from the stream, which is bound to %q", event, bound)
+			}
+		}
+	}
+	return nil
+}
+
+// subjectMatches tells whether the NATS subject matches pattern, which may have the wildcards * and >
+func subjectMatches(pattern, subject string) bool {
+	patternTokens, subjectTokens := strings.Split(pattern, "."), strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		switch {
+		case token == ">":
+			return len(subjectTokens) > i
+		case i >= len(subjectTokens):
+			return false
+		case token != "*" && token != subjectTokens[i]:
+			return false
+		}
+	}
+	return len(patternTokens) == len(subjectTokens)
+}
+
 func (a *Payment) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /payments", a.Handler.List)
 	mux.HandleFunc("POST /payments", a.Handler.Create)
+	mux.HandleFunc("GET /payments:export", a.Bulk.Export)
+	mux.HandleFunc("POST /payments:import", a.Bulk.Import)
 	mux.HandleFunc("GET /payments/{id}", a.Handler.Get)
 	mux.HandleFunc("PUT /payments/{id}", a.Handler.Update)
+	mux.HandleFunc("PATCH /payments/{id}", a.Handler.Patch)
 	mux.HandleFunc("DELETE /payments/{id}", a.Handler.Delete)
+	mux.HandleFunc("POST /payments/{id}", a.Handler.Restore)
+	if a.purge != nil {
+		mux.Handle("GET /payments:retention", planhttp.NewRetentionHandler(a.purge))
+	}
+	mux.Handle("GET /payments/{id}/history", planhttp.NewHistoryHandler(a.Audit, "payment"))
+}
+
+// Check reports whether the service can reach its database and NATS
+func (a *Payment) Check(ctx context.Context) error {
+	if err := storegorm.Ping(ctx, a.db); err != nil {
+		return err
+	}
+	if status := a.natsConn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("NATS connection is %s", status)
+	}
+	return nil
 }
 
 func (a *Payment) Shutdown() error {
 	if a.cctx != nil {
 		a.cctx.Drain()
 	}
-	return a.natsConn.Drain()
+	if a.purge != nil {
+		_ = a.purge.Close()
+	}
+	// the relay publishes what's left in the outbox before the connection is drained
+	errRelay := a.relay.Close()
+	return errors.Join(errRelay, a.natsConn.Drain(), storegorm.Close(a.db))
 }
@@ -0,0 +1,39 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckEventsSubject(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		bound   []string
+		subject string
+		wantErr bool
+	}{
+		{name: "separate subjects", bound: []string{"payment.process"}, subject: "events"},
+		{name: "literal", bound: []string{"events.payment.created"}, subject: "events", wantErr: true},
+		{name: "single token wildcard", bound: []string{"payment.process", "events.*.updated"}, subject: "events", wantErr: true},
+		{name: "tail wildcard", bound: []string{"payment.>"}, subject: "payment", wantErr: true},
+		{name: "tail wildcard of another prefix", bound: []string{"payment.>"}, subject: "events"},
+		{name: "wildcard for fewer tokens", bound: []string{"events.*"}, subject: "events"},
+		{name: "everything", bound: []string{">"}, subject: "events", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// test
+			err := checkEventsSubject(tt.bound, tt.subject)
+
+			// verify
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
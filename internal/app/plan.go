@@ -4,38 +4,165 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/api"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
 	grpchandler "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/grpc"
 	planhttp "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/http"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/outbox"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	storeaudit "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/audit"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/cache"
+	storegorm "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/gorm"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/retention"
 	"google.golang.org/grpc"
+	"gorm.io/gorm"
 )
 
 type Plan struct {
 	Handler     *planhttp.PlanHandler
 	GRPCHandler api.PlanServiceServer
+	Bulk        *planhttp.BulkHandler[model.Plan]
 	Store       store.Plan
+	// Tx runs operations on Store atomically
+	Tx store.Transactor
+	// Audit has the trail of the changes made through Store
+	Audit store.Audit
+	// Outbox has the events announcing the changes made through Store, waiting to be published
+	Outbox store.Outbox
+	// Cache is the cache in front of Store, nil when the cache is disabled
+	Cache *cache.Store[model.Plan]
+
+	db        *gorm.DB
+	snapshots *memory.Snapshots
+	events    *events
+	retention *retention.Job
 }
 
-func NewPlan(*config.Plans) *Plan {
-	store := memory.NewPlanStore()
-	return &Plan{
-		Handler:     planhttp.NewPlanHandler(store),
-		GRPCHandler: grpchandler.NewPlanServer(store),
-		Store:       store,
+func NewPlan(cfg *config.Plans) (*Plan, error) {
+	var (
+		st        store.Plan
+		purger    store.Purger
+		tx        store.Transactor
+		audit     store.Audit
+		ob        store.Outbox
+		db        *gorm.DB
+		snapshots *memory.Snapshots
+		err       error
+	)
+	switch cfg.Store {
+	case "", config.StoreMemory:
+		mem := memory.NewPlanStore()
+		st, purger = mem, mem
+		tx = memory.NewTransactor()
+		audit = memory.NewAuditStore()
+		ob = memory.NewOutboxStore()
+		snapshots, err = startSnapshots(cfg.Snapshot, mem)
+		if err != nil {
+			return nil, err
+		}
+	case config.StoreDatabase, config.StoreSQLite:
+		db, err = openDB(cfg.Database, "plans", storegorm.PlanMigrations)
+		if err != nil {
+			return nil, err
+		}
+		dbStore := storegorm.NewPlanStore(db)
+		st, purger = dbStore, dbStore
+		tx = storegorm.NewTransactor(db)
+		audit = storegorm.NewAuditStore(db, "plans_audit")
+		ob = storegorm.NewOutboxStore(db, "plans_outbox")
+	default:
+		return nil, fmt.Errorf("unknown store %q for plans", cfg.Store)
+	}
+	st = storeaudit.NewPlanStore(st, audit, tx)
+	if cfg.Events.Subject != "" {
+		st = outbox.NewPlanStore(st, ob, tx, cfg.Events.Subject)
+	}
+	evts, err := startEvents(cfg.Events, ob)
+	if err != nil {
+		return nil, err
+	}
+	purge := startRetention("plan", cfg.Retention, purger, tx)
+
+	var cached *cache.Store[model.Plan]
+	if cfg.Cache.TTL > 0 {
+		cached = cache.NewPlanStore(st, cacheOptions(cfg.Cache))
+		st = cached
 	}
+
+	return &Plan{
+		Handler:     planhttp.NewPlanHandler(st),
+		GRPCHandler: grpchandler.NewPlanServer(st),
+		Bulk:        planhttp.NewPlanBulkHandler(st, tx),
+		Store:       st,
+		Tx:          tx,
+		Audit:       audit,
+		Outbox:      ob,
+		Cache:       cached,
+
+		db:        db,
+		snapshots: snapshots,
+		events:    evts,
+		retention: purge,
+	}, nil
 }
 
 func (a *Plan) RegisterRoutes(mux *http.ServeMux, grpcSrv *grpc.Server) {
 	mux.HandleFunc("GET /plans", a.Handler.List)
 	mux.HandleFunc("POST /plans", a.Handler.Create)
+	mux.HandleFunc("GET /plans:export", a.Bulk.Export)
+	mux.HandleFunc("POST /plans:import", a.Bulk.Import)
 	mux.HandleFunc("GET /plans/{id}", a.Handler.Get)
 	mux.HandleFunc("PUT /plans/{id}", a.Handler.Update)
+	mux.HandleFunc("PATCH /plans/{id}", a.Handler.Patch)
 	mux.HandleFunc("DELETE /plans/{id}", a.Handler.Delete)
+	mux.HandleFunc("POST /plans/{id}", a.Handler.Restore)
+	if a.retention != nil {
+		mux.Handle("GET /plans:retention", planhttp.NewRetentionHandler(a.retention))
+	}
+	mux.Handle("GET /plans/{id}/history", planhttp.NewHistoryHandler(a.Audit, "plan"))
 
 	api.RegisterPlanServiceServer(grpcSrv, a.GRPCHandler)
 }
+
+// Check reports whether the service can reach its database and NATS, if it has them
+func (a *Plan) Check(ctx context.Context) error {
+	if a.events != nil {
+		if err := a.events.check(); err != nil {
+			return err
+		}
+	}
+	if a.db == nil {
+		return nil
+	}
+	return storegorm.Ping(ctx, a.db)
+}
+
+// Shutdown publishes the pending events, saves the last snapshot of the store, when snapshots are enabled, and
+// closes the database
+func (a *Plan) Shutdown() error {
+	if a.Cache != nil {
+		logCacheStats("plans", a.Cache.Stats())
+	}
+
+	var errs []error
+	if a.retention != nil {
+		errs = append(errs, a.retention.Close())
+	}
+	if a.events != nil {
+		errs = append(errs, a.events.Close())
+	}
+	if a.snapshots != nil {
+		errs = append(errs, a.snapshots.Close())
+	}
+	if a.db != nil {
+		errs = append(errs, storegorm.Close(a.db))
+	}
+	return errors.Join(errs...)
+}
@@ -10,13 +10,20 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/api"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
 	grpchandler "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/grpc"
 	planhttp "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/http"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/http/middleware"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	storegorm "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/gorm"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -38,14 +45,16 @@ func TestPlan_RegisterRoutes(t *testing.T) {
 	defer grpcServer.Stop()
 
 	mux := http.NewServeMux()
-	plan := NewPlan(&config.Plans{})
+	plan, err := NewPlan(&config.Plans{})
+	require.NoError(t, err)
 	expected := &model.Plan{
 		ID:          "123",
 		Name:        "Test Plan",
 		Description: "This is a test plan",
 		Price:       10,
 	}
-	_, _ = plan.Store.Create(context.Background(), expected)
+	expected, err = plan.Store.Create(context.Background(), expected)
+	require.NoError(t, err)
 
 	// test
 	plan.RegisterRoutes(mux, grpcServer)
@@ -57,9 +66,11 @@ func TestPlan_RegisterRoutes(t *testing.T) {
 		w := httptest.NewRecorder()
 		mux.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
-		var plans []*model.Plan
-		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &plans))
-		assert.Equal(t, expected, plans[0])
+		var resp struct {
+			Plans []*model.Plan `json:"plans"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, expected, resp.Plans[0])
 	}
 
 	{ // grpc
@@ -78,15 +89,76 @@ func TestPlan_RegisterRoutes(t *testing.T) {
 }
 
 func TestNewPlan(t *testing.T) {
-	plan := NewPlan(&config.Plans{})
+	plan, err := NewPlan(&config.Plans{})
+	require.NoError(t, err)
 	assert.NotNil(t, plan.Handler)
 	assert.NotNil(t, plan.GRPCHandler)
 	assert.NotNil(t, plan.Store)
 }
 
+func TestNewPlan_SQLite(t *testing.T) {
+	plan, err := NewPlan(&config.Plans{
+		Store:    config.StoreSQLite,
+		Database: config.Database{Driver: "sqlite-memory", DSN: "TestNewPlan_SQLite", AutoMigrate: true},
+	})
+	require.NoError(t, err)
+	defer plan.Shutdown()
+	require.NoError(t, plan.Check(context.Background()))
+
+	created, err := plan.Store.Create(context.Background(), &model.Plan{ID: "123", Name: "Test Plan"})
+	require.NoError(t, err)
+
+	got, err := plan.Store.Get(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Test Plan", got.Name)
+
+	_, err = plan.Store.Create(context.Background(), &model.Plan{ID: "123"})
+	assert.ErrorIs(t, err, store.ErrConflict)
+
+	_, err = plan.Store.Get(context.Background(), "456")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	updated, err := plan.Store.Update(context.Background(), &model.Plan{ID: "123", Name: "Updated", Version: got.Version})
+	require.NoError(t, err)
+	assert.Equal(t, got.Version+1, updated.Version)
+
+	_, err = plan.Store.Update(context.Background(), &model.Plan{ID: "123", Version: got.Version})
+	assert.ErrorIs(t, err, store.ErrVersionMismatch)
+
+	require.NoError(t, plan.Store.Delete(context.Background(), "123"))
+	_, err = plan.Store.Get(context.Background(), "123")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	restored, err := plan.Store.Restore(context.Background(), "123")
+	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+}
+
+func TestNewPlan_SchemaBehind(t *testing.T) {
+	_, err := NewPlan(&config.Plans{
+		Store:    config.StoreDatabase,
+		Database: config.Database{Driver: "sqlite-memory", DSN: "TestNewPlan_SchemaBehind"},
+	})
+	assert.ErrorIs(t, err, storegorm.ErrSchemaBehind)
+}
+
+func TestNewPlan_UnknownDatabaseDriver(t *testing.T) {
+	_, err := NewPlan(&config.Plans{
+		Store:    config.StoreDatabase,
+		Database: config.Database{Driver: "oracle", AutoMigrate: true},
+	})
+	assert.Error(t, err)
+}
+
+func TestNewPlan_UnknownStore(t *testing.T) {
+	_, err := NewPlan(&config.Plans{Store: "redis"})
+	assert.Error(t, err)
+}
+
 func TestPlanHandler_Handle(t *testing.T) {
 	store := memory.NewPlanStore()
-	plan := NewPlan(&config.Plans{})
+	plan, err := NewPlan(&config.Plans{})
+	require.NoError(t, err)
 	plan.Handler = planhttp.NewPlanHandler(store)
 
 	req, err := http.NewRequest("GET", "/plans", nil)
@@ -100,7 +172,8 @@ func TestPlanHandler_Handle(t *testing.T) {
 
 func TestGRPCHandler(t *testing.T) {
 	store := memory.NewPlanStore()
-	plan := NewPlan(&config.Plans{})
+	plan, err := NewPlan(&config.Plans{})
+	require.NoError(t, err)
 	plan.GRPCHandler = grpchandler.NewPlanServer(store)
 
 	req := &api.ListRequest{}
@@ -110,3 +183,137 @@ func TestGRPCHandler(t *testing.T) {
 
 	assert.Len(t, resp.Plans, 0)
 }
+
+func TestNewPlan_Snapshot(t *testing.T) {
+	// prepare
+	cfg := &config.Plans{
+		Store:    config.StoreMemory,
+		Snapshot: config.Snapshot{Path: filepath.Join(t.TempDir(), "plans.json")},
+	}
+	plan, err := NewPlan(cfg)
+	require.NoError(t, err)
+	_, err = plan.Store.Create(context.Background(), &model.Plan{ID: "123", Name: "Test Plan"})
+	require.NoError(t, err)
+
+	// test
+	require.NoError(t, plan.Shutdown())
+	restarted, err := NewPlan(cfg)
+	require.NoError(t, err)
+	defer restarted.Shutdown()
+
+	// verify
+	got, err := restarted.Store.Get(context.Background(), "123")
+	require.NoError(t, err)
+	assert.Equal(t, "Test Plan", got.Name)
+}
+
+func TestNewPlan_Cache(t *testing.T) {
+	// prepare
+	plan, err := NewPlan(&config.Plans{Cache: config.Cache{TTL: time.Minute}})
+	require.NoError(t, err)
+	defer plan.Shutdown()
+	_, err = plan.Store.Create(context.Background(), &model.Plan{ID: "123", Name: "Test Plan"})
+	require.NoError(t, err)
+
+	// test
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/plans/123", nil)
+		req.SetPathValue("id", "123")
+		rec := httptest.NewRecorder()
+		plan.Handler.Get(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// verify
+	require.NotNil(t, plan.Cache)
+	assert.EqualValues(t, 1, plan.Cache.Stats().Hits)
+}
+
+func TestPlan_History(t *testing.T) {
+	// prepare
+	plan, err := NewPlan(&config.Plans{
+		Store:    config.StoreDatabase,
+		Database: config.Database{Driver: "sqlite-memory", DSN: "TestPlan_History", AutoMigrate: true},
+	})
+	require.NoError(t, err)
+	defer plan.Shutdown()
+	mux := http.NewServeMux()
+	plan.RegisterRoutes(mux, grpc.NewServer())
+	handler := middleware.Default(mux)
+
+	req := httptest.NewRequest(http.MethodPost, "/plans", strings.NewReader(`{"name":"Basic","price":10}`))
+	req.Header.Set(reqctx.ActorHeader, "john")
+	req.Header.Set(reqctx.RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var created model.Plan
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+
+	req = httptest.NewRequest(http.MethodPut, "/plans/"+created.ID, strings.NewReader(`{"id":"`+created.ID+`","name":"Basic","price":20}`))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// test
+	req = httptest.NewRequest(http.MethodGet, "/plans/"+created.ID+"/history", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	// verify
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"claimed_actor":"john"`, "the actor isn't authenticated")
+	var body struct {
+		History []*model.AuditEntry `json:"history"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	require.Len(t, body.History, 2)
+	assert.Equal(t, model.AuditCreate, body.History[0].Action)
+	assert.Equal(t, "john", body.History[0].Actor)
+	assert.Equal(t, "req-1", body.History[0].RequestID)
+	assert.Equal(t, model.AuditUpdate, body.History[1].Action)
+	assert.NotEmpty(t, body.History[1].RequestID, "requests without an ID are given one")
+	assert.Contains(t, body.History[1].Changes, model.FieldChange{Field: "price", Before: json.RawMessage("10"), After: json.RawMessage("20")})
+
+	// test: unknown record
+	req = httptest.NewRequest(http.MethodGet, "/plans/unknown/history", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestPlan_Retention(t *testing.T) {
+	// prepare
+	plan, err := NewPlan(&config.Plans{Retention: config.Retention{Deleted: time.Nanosecond}})
+	require.NoError(t, err)
+	defer plan.Shutdown()
+	mux := http.NewServeMux()
+	plan.RegisterRoutes(mux, grpc.NewServer())
+
+	ctx := context.Background()
+	_, err = plan.Store.Create(ctx, &model.Plan{ID: "123"})
+	require.NoError(t, err)
+	require.NoError(t, plan.Store.Delete(ctx, "123"))
+	time.Sleep(time.Millisecond)
+
+	// test
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/plans:retention", nil))
+
+	// verify
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Pending struct {
+			DryRun bool `json:"dry_run"`
+			Total  int  `json:"total"`
+		} `json:"pending"`
+		LastRun any `json:"last_run"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.True(t, body.Pending.DryRun)
+	assert.Equal(t, 1, body.Pending.Total)
+	assert.Nil(t, body.LastRun)
+
+	_, err = plan.Store.Get(store.WithDeleted(ctx), "123")
+	assert.NoError(t, err, "the report doesn't purge")
+}
@@ -0,0 +1,27 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/retention"
+)
+
+// startRetention starts purging the records of the store s when a retention period is configured for the
+// soft-deleted records or extra rules are given. It returns nil otherwise.
+func startRetention(resource string, cfg config.Retention, s store.Purger, tx store.Transactor, extra ...retention.Rule) *retention.Job {
+	var rules []retention.Rule
+	if cfg.Deleted > 0 {
+		rules = append(rules, retention.Rule{Name: "deleted", Deleted: true, Age: cfg.Deleted})
+	}
+	rules = append(rules, extra...)
+	if len(rules) == 0 {
+		return nil
+	}
+
+	job := retention.NewJob(resource, s, tx, rules, retention.Options{Interval: cfg.Interval, BatchSize: cfg.BatchSize})
+	job.Start()
+	return job
+}
@@ -0,0 +1,18 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+)
+
+// startSnapshots restores the memory store s from its snapshot and keeps saving it, when snapshots are configured.
+// It returns nil otherwise.
+func startSnapshots(cfg config.Snapshot, s memory.Snapshotter) (*memory.Snapshots, error) {
+	if cfg.Path == "" {
+		return nil, nil
+	}
+	return memory.StartSnapshots(s, cfg.Path, cfg.Interval)
+}
@@ -4,31 +4,144 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
 	subscriptionhttp "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/http"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/outbox"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	storeaudit "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/audit"
+	storegorm "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/gorm"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/retention"
+	"gorm.io/gorm"
 )
 
 type Subscription struct {
 	Handler *subscriptionhttp.SubscriptionHandler
+	Bulk    *subscriptionhttp.BulkHandler[model.Subscription]
 	Store   store.Subscription
+	// Tx runs operations on Store atomically
+	Tx store.Transactor
+	// Audit has the trail of the changes made through Store
+	Audit store.Audit
+	// Outbox has the events announcing the changes made through Store, waiting to be published
+	Outbox store.Outbox
+
+	db        *gorm.DB
+	snapshots *memory.Snapshots
+	events    *events
+	retention *retention.Job
 }
 
-func NewSubscription(cfg *config.Subscriptions) *Subscription {
-	store := memory.NewSubscriptionStore()
-	return &Subscription{
-		Handler: subscriptionhttp.NewSubscriptionHandler(store, cfg.UsersEndpoint, cfg.PlansEndpoint),
-		Store:   store,
+func NewSubscription(cfg *config.Subscriptions) (*Subscription, error) {
+	var (
+		st        store.Subscription
+		purger    store.Purger
+		tx        store.Transactor
+		audit     store.Audit
+		ob        store.Outbox
+		db        *gorm.DB
+		snapshots *memory.Snapshots
+		err       error
+	)
+	switch cfg.Store {
+	case "", config.StoreMemory:
+		mem := memory.NewSubscriptionStore()
+		st, purger = mem, mem
+		tx = memory.NewTransactor()
+		audit = memory.NewAuditStore()
+		ob = memory.NewOutboxStore()
+		snapshots, err = startSnapshots(cfg.Snapshot, mem)
+		if err != nil {
+			return nil, err
+		}
+	case config.StoreDatabase, config.StoreSQLite:
+		db, err = openDB(cfg.Database, "subscriptions", storegorm.SubscriptionMigrations)
+		if err != nil {
+			return nil, err
+		}
+		dbStore := storegorm.NewSubscriptionStore(db)
+		st, purger = dbStore, dbStore
+		tx = storegorm.NewTransactor(db)
+		audit = storegorm.NewAuditStore(db, "subscriptions_audit")
+		ob = storegorm.NewOutboxStore(db, "subscriptions_outbox")
+	default:
+		return nil, fmt.Errorf("unknown store %q for subscriptions", cfg.Store)
+	}
+	st = storeaudit.NewSubscriptionStore(st, audit, tx)
+	if cfg.Events.Subject != "" {
+		st = outbox.NewSubscriptionStore(st, ob, tx, cfg.Events.Subject)
 	}
+	evts, err := startEvents(cfg.Events, ob)
+	if err != nil {
+		return nil, err
+	}
+	purge := startRetention("subscription", cfg.Retention, purger, tx)
+
+	return &Subscription{
+		Handler: subscriptionhttp.NewSubscriptionHandler(st, cfg.UsersEndpoint, cfg.PlansEndpoint),
+		Bulk:    subscriptionhttp.NewSubscriptionBulkHandler(st, tx, cfg.UsersEndpoint, cfg.PlansEndpoint),
+		Store:   st,
+		Tx:      tx,
+		Audit:   audit,
+		Outbox:  ob,
+
+		db:        db,
+		snapshots: snapshots,
+		events:    evts,
+		retention: purge,
+	}, nil
 }
 
 func (a *Subscription) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /subscriptions", a.Handler.List)
 	mux.HandleFunc("POST /subscriptions", a.Handler.Create)
+	mux.HandleFunc("GET /subscriptions:export", a.Bulk.Export)
+	mux.HandleFunc("POST /subscriptions:import", a.Bulk.Import)
 	mux.HandleFunc("GET /subscriptions/{id}", a.Handler.Get)
 	mux.HandleFunc("PUT /subscriptions/{id}", a.Handler.Update)
+	mux.HandleFunc("PATCH /subscriptions/{id}", a.Handler.Patch)
 	mux.HandleFunc("DELETE /subscriptions/{id}", a.Handler.Delete)
+	mux.HandleFunc("POST /subscriptions/{id}", a.Handler.Restore)
+	if a.retention != nil {
+		mux.Handle("GET /subscriptions:retention", subscriptionhttp.NewRetentionHandler(a.retention))
+	}
+	mux.Handle("GET /subscriptions/{id}/history", subscriptionhttp.NewHistoryHandler(a.Audit, "subscription"))
+}
+
+// Check reports whether the service can reach its database and NATS, if it has them
+func (a *Subscription) Check(ctx context.Context) error {
+	if a.events != nil {
+		if err := a.events.check(); err != nil {
+			return err
+		}
+	}
+	if a.db == nil {
+		return nil
+	}
+	return storegorm.Ping(ctx, a.db)
+}
+
+// Shutdown publishes the pending events, saves the last snapshot of the store, when snapshots are enabled, and
+// closes the database
+func (a *Subscription) Shutdown() error {
+	var errs []error
+	if a.retention != nil {
+		errs = append(errs, a.retention.Close())
+	}
+	if a.events != nil {
+		errs = append(errs, a.events.Close())
+	}
+	if a.snapshots != nil {
+		errs = append(errs, a.snapshots.Close())
+	}
+	if a.db != nil {
+		errs = append(errs, storegorm.Close(a.db))
+	}
+	return errors.Join(errs...)
 }
@@ -4,31 +4,158 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/config"
 	userhttp "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/http"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/outbox"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	storeaudit "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/audit"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/cache"
+	storegorm "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/gorm"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/retention"
+	"gorm.io/gorm"
 )
 
 type User struct {
 	Handler *userhttp.UserHandler
+	Bulk    *userhttp.BulkHandler[model.User]
 	Store   store.User
+	// Tx runs operations on Store atomically
+	Tx store.Transactor
+	// Audit has the trail of the changes made through Store
+	Audit store.Audit
+	// Outbox has the events announcing the changes made through Store, waiting to be published
+	Outbox store.Outbox
+	// Cache is the cache in front of Store, nil when the cache is disabled
+	Cache *cache.Store[model.User]
+
+	db        *gorm.DB
+	snapshots *memory.Snapshots
+	events    *events
+	retention *retention.Job
 }
 
-func NewUser(*config.Users) *User {
-	store := memory.NewUserStore()
-	return &User{
-		Handler: userhttp.NewUserHandler(store),
-		Store:   store,
+func NewUser(cfg *config.Users) (*User, error) {
+	var (
+		st        store.User
+		purger    store.Purger
+		tx        store.Transactor
+		audit     store.Audit
+		ob        store.Outbox
+		db        *gorm.DB
+		snapshots *memory.Snapshots
+		err       error
+	)
+	switch cfg.Store {
+	case "", config.StoreMemory:
+		mem := memory.NewUserStore()
+		st, purger = mem, mem
+		tx = memory.NewTransactor()
+		audit = memory.NewAuditStore()
+		ob = memory.NewOutboxStore()
+		snapshots, err = startSnapshots(cfg.Snapshot, mem)
+		if err != nil {
+			return nil, err
+		}
+	case config.StoreDatabase, config.StoreSQLite:
+		db, err = openDB(cfg.Database, "users", storegorm.UserMigrations)
+		if err != nil {
+			return nil, err
+		}
+		dbStore := storegorm.NewUserStore(db)
+		st, purger = dbStore, dbStore
+		tx = storegorm.NewTransactor(db)
+		audit = storegorm.NewAuditStore(db, "users_audit")
+		ob = storegorm.NewOutboxStore(db, "users_outbox")
+	default:
+		return nil, fmt.Errorf("unknown store %q for users", cfg.Store)
+	}
+	st = storeaudit.NewUserStore(st, audit, tx)
+	if cfg.Events.Subject != "" {
+		st = outbox.NewUserStore(st, ob, tx, cfg.Events.Subject)
+	}
+	evts, err := startEvents(cfg.Events, ob)
+	if err != nil {
+		return nil, err
+	}
+	purge := startRetention("user", cfg.Retention, purger, tx)
+
+	var cached *cache.Store[model.User]
+	if cfg.Cache.TTL > 0 {
+		cached = cache.NewUserStore(st, cacheOptions(cfg.Cache))
+		st = cached
 	}
+
+	return &User{
+		Handler: userhttp.NewUserHandler(st),
+		Bulk:    userhttp.NewUserBulkHandler(st, tx),
+		Store:   st,
+		Tx:      tx,
+		Audit:   audit,
+		Outbox:  ob,
+		Cache:   cached,
+
+		db:        db,
+		snapshots: snapshots,
+		events:    evts,
+		retention: purge,
+	}, nil
 }
 
 func (a *User) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /users", a.Handler.List)
 	mux.HandleFunc("POST /users", a.Handler.Create)
+	mux.HandleFunc("GET /users:export", a.Bulk.Export)
+	mux.HandleFunc("POST /users:import", a.Bulk.Import)
 	mux.HandleFunc("GET /users/{id}", a.Handler.Get)
 	mux.HandleFunc("PUT /users/{id}", a.Handler.Update)
+	mux.HandleFunc("PATCH /users/{id}", a.Handler.Patch)
 	mux.HandleFunc("DELETE /users/{id}", a.Handler.Delete)
+	mux.HandleFunc("POST /users/{id}", a.Handler.Restore)
+	if a.retention != nil {
+		mux.Handle("GET /users:retention", userhttp.NewRetentionHandler(a.retention))
+	}
+	mux.Handle("GET /users/{id}/history", userhttp.NewHistoryHandler(a.Audit, "user"))
+}
+
+// Check reports whether the service can reach its database and NATS, if it has them
+func (a *User) Check(ctx context.Context) error {
+	if a.events != nil {
+		if err := a.events.check(); err != nil {
+			return err
+		}
+	}
+	if a.db == nil {
+		return nil
+	}
+	return storegorm.Ping(ctx, a.db)
+}
+
+// Shutdown publishes the pending events, saves the last snapshot of the store, when snapshots are enabled, and
+// closes the database
+func (a *User) Shutdown() error {
+	if a.Cache != nil {
+		logCacheStats("users", a.Cache.Stats())
+	}
+
+	var errs []error
+	if a.retention != nil {
+		errs = append(errs, a.retention.Close())
+	}
+	if a.events != nil {
+		errs = append(errs, a.events.Close())
+	}
+	if a.snapshots != nil {
+		errs = append(errs, a.snapshots.Close())
+	}
+	if a.db != nil {
+		errs = append(errs, storegorm.Close(a.db))
+	}
+	return errors.Join(errs...)
 }
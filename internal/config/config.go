@@ -4,8 +4,13 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -29,9 +34,11 @@ type Endpoint struct {
 }
 
 type Payments struct {
-	SubscriptionsEndpoint string  `yaml:"subscriptions_endpoint"`
-	SQLLite               SQLLite `yaml:"sqlite"`
-	NATS                  NATS    `yaml:"nats"`
+	SubscriptionsEndpoint string           `yaml:"subscriptions_endpoint"`
+	Database              Database         `yaml:"database"`
+	SQLite                SQLite           `yaml:"sqlite"`
+	NATS                  NATS             `yaml:"nats"`
+	Retention             PaymentRetention `yaml:"retention"`
 }
 
 type NATS struct {
@@ -39,21 +46,144 @@ type NATS struct {
 	Subject      string `yaml:"subject"`
 	Stream       string `yaml:"stream"`
 	ConsumerName string `yaml:"consumer_name"`
+	// EventsSubject prefixes the subjects of the events announcing the changes made to the payments, published
+	// on <subject>.payment.<action>. Events are disabled when empty. The subjects must not be bound to Stream,
+	// or the service would consume its own events.
+	EventsSubject string `yaml:"events_subject"`
 }
 
-type SQLLite struct {
+// Database configures the SQL database of a service
+type Database struct {
+	// Driver is the name of the database driver: sqlite, sqlite-cgo, sqlite-memory, postgres or mysql
+	Driver string `yaml:"driver"`
+	// DSN is the connection string, in the format of the driver. For sqlite-memory, it names the in-memory
+	// database, shared by the services using the same name, which defaults to the name of the service.
 	DSN string `yaml:"dsn"`
+
+	// MaxOpenConns, MaxIdleConns, ConnMaxLifetime and ConnMaxIdleTime configure the connection pool. Zero
+	// values keep the defaults of database/sql.
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+
+	// AutoMigrate applies the pending schema migrations on startup. When disabled, the service refuses to start
+	// until the migrate command is run.
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+// SQLite is the former section of the database settings, kept for existing configuration files. When its DSN
+// or driver is set, it replaces the settings under `database`.
+//
+// Deprecated: use Database
+type SQLite struct {
+	// Driver is a database driver, "sqlite" when empty. The former "pure" and "cgo" are still accepted.
+	Driver      string `yaml:"driver"`
+	DSN         string `yaml:"dsn"`
+	AutoMigrate *bool  `yaml:"auto_migrate"`
+}
+
+// apply copies the settings of s to db, returning whether there were any
+func (s SQLite) apply(db *Database) bool {
+	if s.DSN == "" && s.Driver == "" {
+		return false
+	}
+	db.Driver = s.Driver
+	if db.Driver == "" {
+		db.Driver = "sqlite"
+	}
+	db.DSN = s.DSN
+	if s.AutoMigrate != nil {
+		db.AutoMigrate = *s.AutoMigrate
+	}
+	return true
+}
+
+// Snapshot configures the snapshots of a memory store. Snapshots are disabled when Path is empty.
+type Snapshot struct {
+	// Path is the file the store is saved to, and loaded from on startup
+	Path string `yaml:"path"`
+	// Interval is how often the store is saved, besides on shutdown. Zero saves only on shutdown.
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Cache configures the read-through cache in front of the store of a service. The cache is disabled when TTL is
+// zero.
+type Cache struct {
+	// TTL is how long a record is served from the cache. Changes made by other instances of the service, or
+	// committed by transactions, may take as long to be seen.
+	TTL time.Duration `yaml:"ttl"`
+	// Size is the maximum number of records in the cache, 1000 when zero
+	Size int `yaml:"size"`
+}
+
+// Events configures the events announcing the changes made to the records of a service. Events are disabled
+// when Subject is empty.
+type Events struct {
+	// Endpoint is the NATS server the events are published to
+	Endpoint string `yaml:"endpoint"`
+	// Subject prefixes the subjects of the events, published on <subject>.<resource>.<action>. The subjects
+	// must be bound to a JetStream stream.
+	Subject string `yaml:"subject"`
+}
+
+// Retention configures the purge of the soft-deleted records of a service. Purges are disabled when Deleted is
+// zero.
+type Retention struct {
+	// Deleted is how long soft-deleted records are kept before being removed for good
+	Deleted time.Duration `yaml:"deleted"`
+	// Interval is how often the records are purged, 1h when zero
+	Interval time.Duration `yaml:"interval"`
+	// BatchSize is the number of records removed per transaction, 500 when zero
+	BatchSize int `yaml:"batch_size"`
+}
+
+// PaymentRetention configures the purge of the payments. Purges are disabled when both Deleted and Failed are
+// zero.
+type PaymentRetention struct {
+	Retention `yaml:",inline"`
+	// Failed is how long failed payments are kept after their last change before being removed for good
+	Failed time.Duration `yaml:"failed"`
 }
 
+const (
+	// StoreMemory keeps the service data in memory, losing it on restarts unless snapshots are enabled
+	StoreMemory = "memory"
+	// StoreDatabase persists the service data in the SQL database configured under `database`
+	StoreDatabase = "database"
+	// StoreSQLite is the former name of StoreDatabase, kept for existing configuration files
+	StoreSQLite = "sqlite"
+)
+
 type Subscriptions struct {
-	UsersEndpoint string `yaml:"users_endpoint"`
-	PlansEndpoint string `yaml:"plans_endpoint"`
+	UsersEndpoint string    `yaml:"users_endpoint"`
+	PlansEndpoint string    `yaml:"plans_endpoint"`
+	Store         string    `yaml:"store"`
+	Database      Database  `yaml:"database"`
+	SQLite        SQLite    `yaml:"sqlite"`
+	Snapshot      Snapshot  `yaml:"snapshot"`
+	Events        Events    `yaml:"events"`
+	Retention     Retention `yaml:"retention"`
 }
 
 type Plans struct {
+	Store     string    `yaml:"store"`
+	Database  Database  `yaml:"database"`
+	SQLite    SQLite    `yaml:"sqlite"`
+	Snapshot  Snapshot  `yaml:"snapshot"`
+	Cache     Cache     `yaml:"cache"`
+	Events    Events    `yaml:"events"`
+	Retention Retention `yaml:"retention"`
 }
 
 type Users struct {
+	Store     string    `yaml:"store"`
+	Database  Database  `yaml:"database"`
+	SQLite    SQLite    `yaml:"sqlite"`
+	Snapshot  Snapshot  `yaml:"snapshot"`
+	Cache     Cache     `yaml:"cache"`
+	Events    Events    `yaml:"events"`
+	Retention Retention `yaml:"retention"`
 }
 
 // LoadConfig loads the configuration from a YAML file
@@ -72,19 +202,53 @@ func LoadConfig(filename string) (*Config, error) {
 	// Replace environment variable placeholders
 	data = []byte(os.ExpandEnv(string(data)))
 
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := decode(data, cfg, false); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	// unknown settings are ignored, so that files written for other versions keep working, but reported, so
+	// that misspelled ones don't go unnoticed
+	var typeErr *yaml.TypeError
+	if err := decode(data, &Config{}, true); errors.As(err, &typeErr) {
+		for _, msg := range typeErr.Errors {
+			slog.Warn("ignoring unknown configuration setting", "file", filename, "error", msg)
+		}
+	}
+
+	for service, section := range map[string]struct {
+		sqlite SQLite
+		db     *Database
+	}{
+		"payments":      {cfg.Payments.SQLite, &cfg.Payments.Database},
+		"subscriptions": {cfg.Subscriptions.SQLite, &cfg.Subscriptions.Database},
+		"plans":         {cfg.Plans.SQLite, &cfg.Plans.Database},
+		"users":         {cfg.Users.SQLite, &cfg.Users.Database},
+	} {
+		if section.sqlite.apply(section.db) {
+			slog.Warn("the sqlite section of the configuration is deprecated, use database instead", "service", service)
+		}
+	}
 
 	return cfg, nil
 }
 
+// decode reads the YAML document in data into cfg, failing on unknown fields when strict
+func decode(data []byte, cfg *Config, strict bool) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(strict)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
 func getDefaultConfig() *Config {
 	return &Config{
 		Payments: Payments{
 			SubscriptionsEndpoint: "http://localhost:8080/subscriptions",
-			SQLLite: SQLLite{
-				DSN: "file::memory:?cache=shared",
+			Database: Database{
+				Driver:      "sqlite-memory",
+				DSN:         "payments",
+				AutoMigrate: true,
 			},
 			NATS: NATS{
 				Endpoint:     "nats://localhost:4222",
@@ -96,9 +260,47 @@ func getDefaultConfig() *Config {
 		Subscriptions: Subscriptions{
 			UsersEndpoint: "http://localhost:8080/users",
 			PlansEndpoint: "http://localhost:8080/plans",
+			Store:         StoreMemory,
+			Database: Database{
+				Driver:      "sqlite-memory",
+				DSN:         "subscriptions",
+				AutoMigrate: true,
+			},
+			Snapshot: Snapshot{
+				Interval: 30 * time.Second,
+			},
+			Events: Events{
+				Endpoint: "nats://localhost:4222",
+			},
+		},
+		Plans: Plans{
+			Store: StoreMemory,
+			Database: Database{
+				Driver:      "sqlite-memory",
+				DSN:         "plans",
+				AutoMigrate: true,
+			},
+			Snapshot: Snapshot{
+				Interval: 30 * time.Second,
+			},
+			Events: Events{
+				Endpoint: "nats://localhost:4222",
+			},
+		},
+		Users: Users{
+			Store: StoreMemory,
+			Database: Database{
+				Driver:      "sqlite-memory",
+				DSN:         "users",
+				AutoMigrate: true,
+			},
+			Snapshot: Snapshot{
+				Interval: 30 * time.Second,
+			},
+			Events: Events{
+				Endpoint: "nats://localhost:4222",
+			},
 		},
-		Plans: Plans{},
-		Users: Users{},
 		Server: Server{
 			Endpoint: Endpoint{
 				GRPC: ":8081",
@@ -0,0 +1,103 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	// prepare
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+payments:
+  database:
+    driver: postgres
+    dsn: host=localhost user=payments
+    max_open_conns: 10
+    conn_max_lifetime: 5m
+`), 0o600))
+
+	// test
+	cfg, err := LoadConfig(path)
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, "postgres", cfg.Payments.Database.Driver)
+	assert.Equal(t, 10, cfg.Payments.Database.MaxOpenConns)
+	assert.Equal(t, 5*time.Minute, cfg.Payments.Database.ConnMaxLifetime)
+	assert.True(t, cfg.Payments.Database.AutoMigrate, "defaults are kept for the settings not in the file")
+	assert.Equal(t, "sqlite-memory", cfg.Plans.Database.Driver)
+	assert.Equal(t, "plans", cfg.Plans.Database.DSN, "the services don't share the in-memory database")
+}
+
+func TestLoadConfig_UnknownField(t *testing.T) {
+	// prepare
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+payments:
+  databse:
+    dsn: file:payments.db
+`), 0o600))
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	// test
+	cfg, err := LoadConfig(path)
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultConfig().Payments.Database, cfg.Payments.Database)
+	assert.Contains(t, buf.String(), "ignoring unknown configuration setting")
+	assert.Contains(t, buf.String(), "databse")
+}
+
+func TestLoadConfig_SQLite(t *testing.T) {
+	// prepare: the database settings used to be under sqlite
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+payments:
+  sqlite:
+    dsn: file:payments.db
+users:
+  store: sqlite
+  sqlite:
+    driver: cgo
+    dsn: file:users.db
+    auto_migrate: false
+`), 0o600))
+
+	// test
+	cfg, err := LoadConfig(path)
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, Database{Driver: "sqlite", DSN: "file:payments.db", AutoMigrate: true}, cfg.Payments.Database)
+	assert.Equal(t, Database{Driver: "cgo", DSN: "file:users.db"}, cfg.Users.Database)
+	assert.Equal(t, "sqlite-memory", cfg.Plans.Database.Driver, "services without the section keep their settings")
+}
+
+func TestLoadConfig_Empty(t *testing.T) {
+	// prepare
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	// test
+	cfg, err := LoadConfig(path)
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, getDefaultConfig(), cfg)
+}
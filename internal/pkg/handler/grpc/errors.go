@@ -0,0 +1,63 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus converts the store errors into gRPC status errors with the matching code. Conflicts carry the
+// record the request clashed with as a ResourceInfo detail, and invalid fields are listed in a BadRequest detail.
+// Errors not wrapping a store error are logged and reported without details, which could reveal internals.
+func toStatus(ctx context.Context, err error) error {
+	var conflict *store.ConflictError
+	if errors.As(err, &conflict) {
+		st, detailsErr := status.New(codes.AlreadyExists, err.Error()).WithDetails(&errdetails.ResourceInfo{
+			ResourceType: conflict.Kind,
+			ResourceName: conflict.ID,
+			Description:  conflict.Reason,
+		})
+		if detailsErr == nil {
+			return st.Err()
+		}
+	}
+
+	var invalid *store.ValidationError
+	if errors.As(err, &invalid) {
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(invalid.Fields))
+		for i, f := range invalid.Fields {
+			violations[i] = &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message}
+		}
+		st, detailsErr := status.New(codes.InvalidArgument, err.Error()).WithDetails(&errdetails.BadRequest{
+			FieldViolations: violations,
+		})
+		if detailsErr == nil {
+			return st.Err()
+		}
+	}
+
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, store.ErrConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, store.ErrInvalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, store.ErrVersionMismatch):
+		return status.Error(codes.Aborted, err.Error())
+	default:
+		method, _ := grpc.Method(ctx)
+		slog.Error("failed to serve call", "method", method, "request_id", reqctx.RequestID(ctx), "error", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatus_Conflict(t *testing.T) {
+	// prepare
+	err := fmt.Errorf("creating user: %w", store.EmailInUse("123", "john@example.com"))
+
+	// test
+	st := status.Convert(toStatus(context.Background(), err))
+
+	// verify
+	assert.Equal(t, codes.AlreadyExists, st.Code())
+	require.Len(t, st.Details(), 1)
+	info, ok := st.Details()[0].(*errdetails.ResourceInfo)
+	require.True(t, ok)
+	assert.Equal(t, "user", info.ResourceType)
+	assert.Equal(t, "123", info.ResourceName)
+}
+
+func TestToStatus_Invalid(t *testing.T) {
+	// prepare
+	err := &store.ValidationError{Fields: []store.FieldError{
+		{Field: "name", Message: "is required"},
+		{Field: "price", Message: "must be at least 0"},
+	}}
+
+	// test
+	st := status.Convert(toStatus(context.Background(), err))
+
+	// verify
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+	details, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+	require.Len(t, details.FieldViolations, 2)
+	assert.Equal(t, "name", details.FieldViolations[0].Field)
+	assert.Equal(t, "must be at least 0", details.FieldViolations[1].Description)
+}
+
+func TestToStatus_Internal(t *testing.T) {
+	// prepare
+	err := fmt.Errorf("listing plans: %w", errors.New("dial tcp 10.0.0.7:5432: connection refused"))
+
+	// test
+	st := status.Convert(toStatus(context.Background(), err))
+
+	// verify
+	assert.Equal(t, codes.Internal, st.Code())
+	assert.Equal(t, "internal error", st.Message())
+}
@@ -5,11 +5,14 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/api"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/validate"
+	"google.golang.org/grpc/metadata"
 )
 
 type planServer struct {
@@ -25,109 +28,171 @@ func NewPlanServer(store store.Plan) api.PlanServiceServer {
 }
 
 func (s *planServer) Get(ctx context.Context, req *api.GetRequest) (*api.GetResponse, error) {
+	if req.IncludeDeleted {
+		ctx = store.WithDeleted(ctx)
+	}
+
 	plan, err := s.store.Get(ctx, req.Id)
 	if err != nil {
-		return nil, err
+		return nil, toStatus(ctx, err)
 	}
 
 	resp := &api.GetResponse{
-		Plan: &api.Plan{
-			Id:          plan.ID,
-			Name:        plan.Name,
-			Description: plan.Description,
-			Price:       plan.Price,
-			Version:     plan.Version,
-			CreatedAt:   plan.CreatedAt.Format(time.RFC3339),
-			UpdatedAt:   plan.UpdatedAt.Format(time.RFC3339),
-			DeletedAt:   plan.DeletedAt.Format(time.RFC3339),
-		},
+		Plan: toAPIPlan(plan),
 	}
 	return resp, nil
 }
 
 func (s *planServer) Create(ctx context.Context, req *api.CreateRequest) (*api.CreateResponse, error) {
-	plan, err := s.store.Create(ctx, &model.Plan{
-		ID:          req.Plan.Id,
+	if req.Plan == nil {
+		return nil, toStatus(ctx, missing("plan"))
+	}
+
+	// the ID, version and timestamps are assigned by the store
+	plan := &model.Plan{
 		Name:        req.Plan.Name,
 		Description: req.Plan.Description,
 		Price:       req.Plan.Price,
-		Version:     req.Plan.Version,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	})
+	}
+	if err := validate.Record(plan); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	plan, err := s.store.Create(ctx, plan)
 	if err != nil {
-		return nil, err
+		return nil, toStatus(ctx, err)
 	}
 
 	resp := &api.CreateResponse{
-		Plan: &api.Plan{
-			Id:          plan.ID,
-			Name:        plan.Name,
-			Description: plan.Description,
-			Price:       plan.Price,
-			Version:     plan.Version,
-			CreatedAt:   plan.CreatedAt.Format(time.RFC3339),
-			UpdatedAt:   plan.UpdatedAt.Format(time.RFC3339),
-		},
+		Plan: toAPIPlan(plan),
 	}
 	return resp, nil
 }
 
+// Update replaces the plan identified by req.Plan.Id, which must exist. Calls with the metadata
+// If-None-Match: * create the plan with the ID instead, failing with AlreadyExists when a plan, even a deleted
+// one, has it.
 func (s *planServer) Update(ctx context.Context, req *api.UpdateRequest) (*api.UpdateResponse, error) {
-	plan, err := s.store.Update(ctx, &model.Plan{
+	if req.Plan == nil {
+		return nil, toStatus(ctx, missing("plan"))
+	}
+	if req.Plan.Id == "" {
+		return nil, toStatus(ctx, missing("plan.id"))
+	}
+	if md, _ := metadata.FromIncomingContext(ctx); first(md.Get(IfNoneMatchMetadata)) == "*" {
+		return s.createAt(ctx, req.Plan)
+	}
+
+	plan := &model.Plan{
 		ID:          req.Plan.Id,
 		Name:        req.Plan.Name,
 		Description: req.Plan.Description,
 		Price:       req.Plan.Price,
 		Version:     req.Plan.Version,
-		UpdatedAt:   time.Now(),
-	})
+	}
+	if err := validate.Record(plan); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	plan, err := s.store.Update(ctx, plan)
 	if err != nil {
-		return nil, err
+		return nil, toStatus(ctx, err)
 	}
 
 	resp := &api.UpdateResponse{
-		Plan: &api.Plan{
-			Id:          plan.ID,
-			Name:        plan.Name,
-			Description: plan.Description,
-			Price:       plan.Price,
-			Version:     plan.Version,
-			CreatedAt:   plan.CreatedAt.Format(time.RFC3339),
-			UpdatedAt:   plan.UpdatedAt.Format(time.RFC3339),
-		},
+		Plan: toAPIPlan(plan),
 	}
 	return resp, nil
 }
 
+// createAt creates the plan with the ID it was given
+func (s *planServer) createAt(ctx context.Context, req *api.Plan) (*api.UpdateResponse, error) {
+	plan := &model.Plan{
+		ID:          req.Id,
+		Name:        req.Name,
+		Description: req.Description,
+		Price:       req.Price,
+	}
+	if err := validate.Record(plan); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	_, err := s.store.Get(store.WithDeleted(ctx), plan.ID)
+	switch {
+	case err == nil:
+		return nil, toStatus(ctx, &store.ConflictError{Kind: "plan", ID: plan.ID, Reason: "already exists"})
+	case !errors.Is(err, store.ErrNotFound):
+		return nil, toStatus(ctx, err)
+	}
+
+	plan, err = s.store.Create(ctx, plan)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &api.UpdateResponse{Plan: toAPIPlan(plan)}, nil
+}
+
 func (s *planServer) Delete(ctx context.Context, req *api.DeleteRequest) (*api.DeleteResponse, error) {
 	err := s.store.Delete(ctx, req.Id)
 	if err != nil {
-		return nil, err
+		return nil, toStatus(ctx, err)
 	}
 	return &api.DeleteResponse{}, nil
 }
 
+func (s *planServer) Restore(ctx context.Context, req *api.RestoreRequest) (*api.RestoreResponse, error) {
+	plan, err := s.store.Restore(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	resp := &api.RestoreResponse{
+		Plan: toAPIPlan(plan),
+	}
+	return resp, nil
+}
+
 func (s *planServer) List(ctx context.Context, req *api.ListRequest) (*api.ListResponse, error) {
-	plans, err := s.store.List(ctx)
+	if req.IncludeDeleted {
+		ctx = store.WithDeleted(ctx)
+	}
+
+	plans, next, err := s.store.List(ctx, store.ListOptions{
+		PageSize: int(req.PageSize),
+		Cursor:   req.PageToken,
+	})
 	if err != nil {
-		return nil, err
+		return nil, toStatus(ctx, err)
 	}
 
 	resp := &api.ListResponse{
-		Plans: make([]*api.Plan, len(plans)),
+		Plans:         make([]*api.Plan, len(plans)),
+		NextPageToken: next,
 	}
 
 	for i, plan := range plans {
-		resp.Plans[i] = &api.Plan{
-			Id:          plan.ID,
-			Name:        plan.Name,
-			Description: plan.Description,
-			Price:       plan.Price,
-			Version:     plan.Version,
-			CreatedAt:   plan.CreatedAt.Format(time.RFC3339),
-			UpdatedAt:   plan.UpdatedAt.Format(time.RFC3339),
-		}
+		resp.Plans[i] = toAPIPlan(plan)
 	}
 	return resp, nil
 }
+
+// missing returns the error for requests without the given field
+func missing(field string) error {
+	return &store.ValidationError{Fields: []store.FieldError{{Field: field, Message: "is required"}}}
+}
+
+func toAPIPlan(plan *model.Plan) *api.Plan {
+	ret := &api.Plan{
+		Id:          plan.ID,
+		Name:        plan.Name,
+		Description: plan.Description,
+		Price:       plan.Price,
+		Version:     plan.Version,
+		CreatedAt:   plan.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   plan.UpdatedAt.Format(time.RFC3339),
+	}
+	if plan.DeletedAt != nil {
+		ret.DeletedAt = plan.DeletedAt.Format(time.RFC3339)
+	}
+	return ret
+}
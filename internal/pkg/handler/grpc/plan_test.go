@@ -13,6 +13,9 @@ import (
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 var _ api.PlanServiceServer = (*planServer)(nil)
@@ -50,13 +53,15 @@ func TestPlanServer_Create(t *testing.T) {
 	resp, err := srv.Create(context.Background(), req)
 	assert.NoError(t, err)
 	assert.NotNil(t, resp)
-	assert.Equal(t, req.Plan.Id, resp.Plan.Id)
 
 	// verify
+	assert.NotEmpty(t, resp.Plan.Id)
+	assert.NotEqual(t, req.Plan.Id, resp.Plan.Id, "the client ID should be ignored")
 	plan, err := store.Get(context.Background(), resp.Plan.Id)
 	assert.NoError(t, err)
 	assert.NotNil(t, plan)
-	assert.Equal(t, req.Plan.Id, plan.ID)
+	assert.Equal(t, req.Plan.Name, plan.Name)
+	assert.False(t, plan.CreatedAt.IsZero())
 }
 
 func TestPlanServer_Update(t *testing.T) {
@@ -86,6 +91,27 @@ func TestPlanServer_Update(t *testing.T) {
 	assert.Equal(t, req.Plan.Name, plan.Name)
 }
 
+func TestPlanServer_UpdateStaleVersion(t *testing.T) {
+	// prepare
+	store := memory.NewPlanStore()
+	createTestPlan(t, store)
+	srv := NewPlanServer(store)
+	update := func(version int32) (*api.UpdateResponse, error) {
+		return srv.Update(context.Background(), &api.UpdateRequest{
+			Plan: &api.Plan{Id: "123", Name: "Updated Test Plan", Version: version},
+		})
+	}
+
+	// test
+	resp, err := update(1)
+	require.NoError(t, err)
+	_, err = update(1)
+
+	// verify
+	assert.Equal(t, int32(2), resp.Plan.Version)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+}
+
 func TestPlanServer_Delete(t *testing.T) {
 	// prepare
 	store := memory.NewPlanStore()
@@ -98,9 +124,49 @@ func TestPlanServer_Delete(t *testing.T) {
 	assert.NoError(t, err)
 
 	// verify
-	plan, err := store.Get(context.Background(), req.Id)
-	assert.Nil(t, err)
-	assert.Nil(t, plan)
+	_, err = srv.Get(context.Background(), &api.GetRequest{Id: req.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestPlanServer_Errors(t *testing.T) {
+	// prepare
+	store := memory.NewPlanStore()
+	createTestPlan(t, store)
+	srv := NewPlanServer(store)
+
+	// test
+	_, errGet := srv.Get(context.Background(), &api.GetRequest{Id: "unknown"})
+	_, errUpdate := srv.Update(context.Background(), &api.UpdateRequest{Plan: &api.Plan{Id: "unknown", Name: "Basic"}})
+	_, errDelete := srv.Delete(context.Background(), &api.DeleteRequest{Id: "unknown"})
+
+	// verify
+	assert.Equal(t, codes.NotFound, status.Code(errGet))
+	assert.Equal(t, codes.NotFound, status.Code(errUpdate))
+	assert.Equal(t, codes.NotFound, status.Code(errDelete))
+}
+
+func TestPlanServer_Restore(t *testing.T) {
+	// prepare
+	store := memory.NewPlanStore()
+	createTestPlan(t, store)
+	srv := NewPlanServer(store)
+	_, err := srv.Delete(context.Background(), &api.DeleteRequest{Id: "123"})
+	require.NoError(t, err)
+
+	// test
+	deleted, err := srv.Get(context.Background(), &api.GetRequest{Id: "123", IncludeDeleted: true})
+	require.NoError(t, err)
+	listed, err := srv.List(context.Background(), &api.ListRequest{})
+	require.NoError(t, err)
+	resp, err := srv.Restore(context.Background(), &api.RestoreRequest{Id: "123"})
+	require.NoError(t, err)
+
+	// verify
+	assert.NotEmpty(t, deleted.Plan.DeletedAt)
+	assert.Empty(t, listed.Plans)
+	assert.Empty(t, resp.Plan.DeletedAt)
+	_, err = srv.Get(context.Background(), &api.GetRequest{Id: "123"})
+	assert.NoError(t, err)
 }
 
 func TestPlanServer_List(t *testing.T) {
@@ -119,6 +185,40 @@ func TestPlanServer_List(t *testing.T) {
 	assert.Len(t, resp.Plans, 1)
 }
 
+func TestPlanServer_UpdateMissing(t *testing.T) {
+	// prepare
+	store := memory.NewPlanStore()
+	createTestPlan(t, store)
+	srv := NewPlanServer(store)
+	upsert := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IfNoneMatchMetadata, "*"))
+
+	// test
+	_, errNoID := srv.Update(context.Background(), &api.UpdateRequest{Plan: &api.Plan{Name: "Basic"}})
+	created, errCreated := srv.Update(upsert, &api.UpdateRequest{Plan: &api.Plan{Id: "456", Name: "Basic", Price: 5}})
+	_, errExisting := srv.Update(upsert, &api.UpdateRequest{Plan: &api.Plan{Id: "123", Name: "Basic"}})
+
+	// verify
+	assert.Equal(t, codes.InvalidArgument, status.Code(errNoID))
+	require.NoError(t, errCreated)
+	assert.Equal(t, "456", created.Plan.Id)
+	assert.Equal(t, int32(5), created.Plan.Price)
+	assert.Equal(t, codes.AlreadyExists, status.Code(errExisting))
+}
+
+func TestPlanServer_NoPlan(t *testing.T) {
+	// prepare
+	srv := NewPlanServer(memory.NewPlanStore())
+	ctx := context.Background()
+
+	// test
+	_, errCreate := srv.Create(ctx, &api.CreateRequest{})
+	_, errUpdate := srv.Update(ctx, &api.UpdateRequest{})
+
+	// verify
+	assert.Equal(t, codes.InvalidArgument, status.Code(errCreate))
+	assert.Equal(t, codes.InvalidArgument, status.Code(errUpdate))
+}
+
 func createTestPlan(t *testing.T, store store.Plan) {
 	_, err := store.Create(context.Background(), &model.Plan{
 		ID:          "123",
@@ -0,0 +1,45 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package grpc
+
+import (
+	"context"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// RequestIDMetadata carries the ID of a call
+	RequestIDMetadata = "x-request-id"
+	// ActorMetadata tells who the client making a call claims to be. It isn't authenticated.
+	ActorMetadata = "x-actor"
+	// IfNoneMatchMetadata set to "*" makes Update create the record when it doesn't exist
+	IfNoneMatchMetadata = "if-none-match"
+)
+
+// UnaryRequestContext is a server interceptor making the ID and actor of the calls available to the handlers
+// through reqctx. Calls without an ID are given a new one.
+func UnaryRequestContext(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	id := first(md.Get(RequestIDMetadata))
+	if id == "" {
+		id = store.NewID()
+	}
+	ctx = reqctx.WithRequestID(ctx, id)
+	if actor := first(md.Get(ActorMetadata)); actor != "" {
+		ctx = reqctx.WithActor(ctx, actor)
+	}
+	return handler(ctx, req)
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,285 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/validate"
+)
+
+// MaxImportLine is the longest line accepted by imports, in bytes
+const MaxImportLine = 1 << 20
+
+// The outcomes of the lines of an import
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportFailed  = "failed"
+)
+
+// ImportResult is the outcome of a line of an import
+type ImportResult struct {
+	// Line is the number of the line in the request body, starting at 1
+	Line   int    `json:"line"`
+	ID     string `json:"id,omitempty"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+	// Errors tells which fields of the record are invalid, and why
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// bulkStore is the part of the store interfaces used by imports and exports
+type bulkStore[T any] interface {
+	Get(ctx context.Context, id string) (*T, error)
+	Create(ctx context.Context, record *T) (*T, error)
+	Update(ctx context.Context, record *T) (*T, error)
+	List(ctx context.Context, opts store.ListOptions) ([]*T, string, error)
+}
+
+// BulkHandler imports and exports the records of a store as NDJSON, one record per line, serving
+// GET /{resource}:export and POST /{resource}:import
+type BulkHandler[T any] struct {
+	store bulkStore[T]
+	tx    store.Transactor
+	id    func(*T) string
+	// refs returns the records of other services the record refers to, which must exist
+	refs func(*T) []reference
+}
+
+// NewUserBulkHandler returns a BulkHandler for the users of s. The transactor must run its transactions on s.
+func NewUserBulkHandler(s store.User, tx store.Transactor) *BulkHandler[model.User] {
+	return &BulkHandler[model.User]{store: s, tx: tx, id: func(u *model.User) string { return u.ID }}
+}
+
+// NewPlanBulkHandler returns a BulkHandler for the plans of s
+func NewPlanBulkHandler(s store.Plan, tx store.Transactor) *BulkHandler[model.Plan] {
+	return &BulkHandler[model.Plan]{store: s, tx: tx, id: func(p *model.Plan) string { return p.ID }}
+}
+
+// NewSubscriptionBulkHandler returns a BulkHandler for the subscriptions of s, whose users and plans are looked
+// up at the given endpoints
+func NewSubscriptionBulkHandler(s store.Subscription, tx store.Transactor, usersEndpoint string, plansEndpoint string) *BulkHandler[model.Subscription] {
+	return &BulkHandler[model.Subscription]{
+		store: s,
+		tx:    tx,
+		id:    func(sub *model.Subscription) string { return sub.ID },
+		refs: func(sub *model.Subscription) []reference {
+			return subscriptionReferences(usersEndpoint, plansEndpoint, sub)
+		},
+	}
+}
+
+// NewPaymentBulkHandler returns a BulkHandler for the payments of s, whose subscriptions are looked up at the
+// given endpoint
+func NewPaymentBulkHandler(s store.Payment, tx store.Transactor, subscriptionsEndpoint string) *BulkHandler[model.Payment] {
+	return &BulkHandler[model.Payment]{
+		store: s,
+		tx:    tx,
+		id:    func(p *model.Payment) string { return p.ID },
+		refs: func(p *model.Payment) []reference {
+			return paymentReferences(subscriptionsEndpoint, p)
+		},
+	}
+}
+
+// Export streams the records matching the same filters and sort order as List, page after page. Errors found
+// after the first record was written can't change the response anymore, and cut the export short.
+func (h *BulkHandler[T]) Export(w http.ResponseWriter, r *http.Request) {
+	ctx, err := readContext(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	opts, err := listOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	opts.PageSize = store.MaxPageSize
+	opts.Cursor = ""
+
+	enc := json.NewEncoder(w)
+	for page := 0; ; page++ {
+		records, next, err := h.store.List(ctx, opts)
+		if err != nil {
+			if page == 0 {
+				writeError(w, r, err)
+			} else {
+				slog.Error("failed to export records", "error", err)
+			}
+			return
+		}
+
+		if page == 0 {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		for _, record := range records {
+			if err := enc.Encode(record); err != nil {
+				return
+			}
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+
+		if next == "" {
+			return
+		}
+		opts.Cursor = next
+	}
+}
+
+// errDryRun rolls back the transaction of dry-run imports
+var errDryRun = errors.New("dry run")
+
+// Import creates or updates a record for each line of the request body, writing the outcome of each line as
+// NDJSON. Records without an ID, or whose ID isn't found, are created, and the others are updated, honoring
+// their version when set. A failed line doesn't stop the import. With ?dry_run=true, the whole import is rolled
+// back once done, so that the results tell what would happen without changing anything. Dry runs need an
+// isolated transactor: other clients would see the changes before the rollback, which could also undo the
+// changes they made to the same records in the meantime.
+func (h *BulkHandler[T]) Import(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, r, fmt.Errorf("invalid dry_run parameter %q: %w", value, store.ErrInvalid))
+			return
+		}
+	}
+	if dryRun && !store.Isolated(h.tx) {
+		WriteProblem(w, NewProblem(r, http.StatusNotImplemented, "dry runs need a store with isolated transactions, like the database store"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	run := func(ctx context.Context) error {
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(nil, MaxImportLine)
+
+		line := 0
+		for scanner.Scan() {
+			line++
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			if err := enc.Encode(h.importLine(ctx, line, scanner.Bytes())); err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return enc.Encode(&ImportResult{Line: line + 1, Result: ImportFailed, Error: err.Error()})
+		}
+		return nil
+	}
+
+	var err error
+	if dryRun {
+		err = h.tx.WithTx(r.Context(), func(ctx context.Context) error {
+			if err := run(ctx); err != nil {
+				return err
+			}
+			return errDryRun
+		})
+		if errors.Is(err, errDryRun) {
+			err = nil
+		}
+	} else {
+		err = run(r.Context())
+	}
+	if err != nil {
+		slog.Error("failed to import records", "error", err)
+	}
+}
+
+// importLine creates or updates the record in data, in a transaction of its own, once the records it refers to
+// are found
+func (h *BulkHandler[T]) importLine(ctx context.Context, line int, data []byte) *ImportResult {
+	result := &ImportResult{Line: line}
+
+	record := new(T)
+	if err := json.Unmarshal(data, record); err != nil {
+		result.Result = ImportFailed
+		result.Error = fmt.Sprintf("invalid record: %s", err)
+		return result
+	}
+	result.ID = h.id(record)
+	if err := validate.Record(record); err != nil {
+		fail(ctx, result, err)
+		return result
+	}
+	if h.refs != nil {
+		if err := checkReferences(ctx, h.refs(record)...); err != nil {
+			fail(ctx, result, err)
+			return result
+		}
+	}
+
+	err := h.tx.WithTx(ctx, func(ctx context.Context) error {
+		var (
+			saved *T
+			err   error
+		)
+		result.Result = ImportCreated
+		if id := h.id(record); id != "" {
+			_, err = h.store.Get(ctx, id)
+			switch {
+			case err == nil:
+				result.Result = ImportUpdated
+			case !errors.Is(err, store.ErrNotFound):
+				return err
+			}
+		}
+
+		if result.Result == ImportUpdated {
+			saved, err = h.store.Update(ctx, record)
+		} else {
+			saved, err = h.store.Create(ctx, record)
+		}
+		if err != nil {
+			return err
+		}
+		result.ID = h.id(saved)
+		return nil
+	})
+	if err != nil {
+		result.ID = h.id(record)
+		fail(ctx, result, err)
+	}
+	return result
+}
+
+// fail marks result as failed because of err, which is reported the same way as by writeError: errors not
+// wrapping a store error are logged and reported without details
+func fail(ctx context.Context, result *ImportResult, err error) {
+	result.Result = ImportFailed
+	if statusCode(err) == http.StatusInternalServerError {
+		slog.Error("failed to import record", "line", result.Line, "id", result.ID,
+			"request_id", reqctx.RequestID(ctx), "error", err)
+		result.Error = "the line failed, the request ID identifies it in the logs"
+		return
+	}
+
+	result.Error = err.Error()
+	var invalid *store.ValidationError
+	if errors.As(err, &invalid) {
+		for _, f := range invalid.Fields {
+			result.Errors = append(result.Errors, FieldError{Field: f.Field, Message: f.Message})
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// isolatedTransactor lets the memory transactor run dry runs, which is safe as long as the test is the only
+// client of the store
+type isolatedTransactor struct {
+	store.Transactor
+}
+
+func (isolatedTransactor) Isolated() bool {
+	return true
+}
+
+func newBulkMux(s store.Plan) *http.ServeMux {
+	return newBulkMuxTx(s, isolatedTransactor{memory.NewTransactor()})
+}
+
+func newBulkMuxTx(s store.Plan, tx store.Transactor) *http.ServeMux {
+	h := NewPlanBulkHandler(s, tx)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /plans:export", h.Export)
+	mux.HandleFunc("POST /plans:import", h.Import)
+	return mux
+}
+
+// readLines decodes each line of an NDJSON body
+func readLines[T any](t *testing.T, body string) []T {
+	var ret []T
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		var v T
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &v))
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+func TestBulkHandler_Export(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	s := memory.NewPlanStore()
+	for i := range store.MaxPageSize + 2 {
+		_, err := s.Create(ctx, &model.Plan{ID: fmt.Sprintf("plan-%04d", i), Name: "Basic"})
+		require.NoError(t, err)
+	}
+	_, err := s.Create(ctx, &model.Plan{ID: "other", Name: "Premium"})
+	require.NoError(t, err)
+
+	// test
+	w := httptest.NewRecorder()
+	newBulkMux(s).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plans:export?name=Basic&page_size=1", nil))
+
+	// verify
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	plans := readLines[model.Plan](t, w.Body.String())
+	require.Len(t, plans, store.MaxPageSize+2)
+	assert.Equal(t, "plan-0000", plans[0].ID)
+	assert.Equal(t, "plan-1001", plans[len(plans)-1].ID)
+}
+
+func TestBulkHandler_Import(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	s := memory.NewPlanStore()
+	_, err := s.Create(ctx, &model.Plan{ID: "plan-1", Name: "Basic"})
+	require.NoError(t, err)
+
+	body := strings.Join([]string{
+		`{"name":"New"}`,
+		`{"id":"plan-1","name":"Updated"}`,
+		``,
+		`{"id":"plan-2","name":"Legacy","created_at":"2020-01-01T00:00:00Z"}`,
+		`{"id":"plan-1","name":"Stale","version":1}`,
+		`{"name":`,
+	}, "\n")
+
+	for _, dryRun := range []bool{true, false} {
+		t.Run(fmt.Sprintf("dry run %t", dryRun), func(t *testing.T) {
+			// test
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/plans:import?dry_run=%t", dryRun), strings.NewReader(body))
+			newBulkMux(s).ServeHTTP(w, req)
+
+			// verify
+			require.Equal(t, http.StatusOK, w.Code)
+			results := readLines[ImportResult](t, w.Body.String())
+			require.Len(t, results, 5)
+
+			assert.Equal(t, ImportCreated, results[0].Result)
+			assert.NotEmpty(t, results[0].ID)
+			assert.Equal(t, ImportResult{Line: 2, ID: "plan-1", Result: ImportUpdated}, results[1])
+			assert.Equal(t, ImportResult{Line: 4, ID: "plan-2", Result: ImportCreated}, results[2])
+
+			assert.Equal(t, 5, results[3].Line)
+			assert.Equal(t, ImportFailed, results[3].Result, "plan-1 is at version 2 after the second line")
+			assert.Contains(t, results[3].Error, "version")
+
+			assert.Equal(t, 6, results[4].Line)
+			assert.Equal(t, ImportFailed, results[4].Result)
+
+			plan, err := s.Get(ctx, "plan-1")
+			require.NoError(t, err)
+			_, errLegacy := s.Get(ctx, "plan-2")
+			if dryRun {
+				assert.Equal(t, "Basic", plan.Name)
+				assert.ErrorIs(t, errLegacy, store.ErrNotFound)
+			} else {
+				assert.Equal(t, "Updated", plan.Name)
+				assert.NoError(t, errLegacy)
+			}
+		})
+	}
+}
+
+func TestBulkHandler_ImportReferences(t *testing.T) {
+	// prepare
+	users := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/user-1" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer users.Close()
+	plans := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/plans/plan-1" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer plans.Close()
+
+	s := memory.NewSubscriptionStore()
+	h := NewSubscriptionBulkHandler(s, memory.NewTransactor(), users.URL+"/users", plans.URL+"/plans")
+	body := strings.Join([]string{
+		`{"id":"sub-1","user_id":"user-1","plan_id":"plan-1"}`,
+		`{"id":"sub-2","user_id":"user-2","plan_id":"plan-1"}`,
+		`{"id":"sub-3","user_id":"user-1","plan_id":"plan-2"}`,
+	}, "\n")
+
+	// test
+	w := httptest.NewRecorder()
+	h.Import(w, httptest.NewRequest(http.MethodPost, "/subscriptions:import", strings.NewReader(body)))
+
+	// verify
+	results := readLines[ImportResult](t, w.Body.String())
+	require.Len(t, results, 3)
+	assert.Equal(t, ImportResult{Line: 1, ID: "sub-1", Result: ImportCreated}, results[0])
+
+	assert.Equal(t, ImportFailed, results[1].Result)
+	assert.Equal(t, []FieldError{{Field: "user_id", Message: "not found"}}, results[1].Errors)
+
+	assert.Equal(t, ImportFailed, results[2].Result)
+	assert.Equal(t, "the plans service is unavailable", results[2].Error)
+
+	_, err := s.Get(context.Background(), "sub-2")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+// brokenPlanStore fails to create plans, with an error that isn't a store error
+type brokenPlanStore struct {
+	store.Plan
+}
+
+func (brokenPlanStore) Create(context.Context, *model.Plan) (*model.Plan, error) {
+	return nil, errors.New("dial tcp 10.0.0.7:5432: connection refused")
+}
+
+func TestBulkHandler_ImportInternalError(t *testing.T) {
+	// test
+	w := httptest.NewRecorder()
+	newBulkMux(brokenPlanStore{memory.NewPlanStore()}).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/plans:import", strings.NewReader(`{"name":"Basic"}`)))
+
+	// verify
+	results := readLines[ImportResult](t, w.Body.String())
+	require.Len(t, results, 1)
+	assert.Equal(t, ImportFailed, results[0].Result)
+	assert.NotContains(t, results[0].Error, "10.0.0.7")
+	assert.Contains(t, results[0].Error, "request ID")
+}
+
+func TestBulkHandler_ImportDryRunNotIsolated(t *testing.T) {
+	// prepare
+	s := memory.NewPlanStore()
+	req := httptest.NewRequest(http.MethodPost, "/plans:import?dry_run=true", strings.NewReader(`{"id":"plan-1","name":"Basic"}`))
+
+	// test
+	w := httptest.NewRecorder()
+	newBulkMuxTx(s, memory.NewTransactor()).ServeHTTP(w, req)
+
+	// verify
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	_, err := s.Get(context.Background(), "plan-1")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func TestBulkHandler_ImportInvalidDryRun(t *testing.T) {
+	w := httptest.NewRecorder()
+	newBulkMux(memory.NewPlanStore()).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/plans:import?dry_run=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
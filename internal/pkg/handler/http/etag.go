@@ -0,0 +1,38 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// setETag exposes the version of the record being returned as its entity tag
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// ifMatch returns the version the client expects the record to be at, as stated by the If-Match header.
+// When the header is absent or is "*", the returned version is zero, meaning any version.
+func ifMatch(r *http.Request) (int64, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(value, "W/"), `"`), 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid If-Match header %q: %w", value, store.ErrInvalid)
+	}
+	return version, nil
+}
+
+// ifNoneMatchAny tells whether the If-None-Match header is "*", meaning the client expects no record to exist
+func ifNoneMatchAny(r *http.Request) bool {
+	return strings.TrimSpace(r.Header.Get("If-None-Match")) == "*"
+}
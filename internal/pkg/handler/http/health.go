@@ -0,0 +1,48 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// HealthCheck returns an error when a dependency of a service, like its database, is unhealthy
+type HealthCheck func(ctx context.Context) error
+
+// HealthCheckTimeout bounds the time taken by each of the health checks
+const HealthCheckTimeout = 2 * time.Second
+
+// HealthHandler serves the health of the services as a JSON object mapping the name of each service to "ok"
+// or to the error returned by its check
+type HealthHandler struct {
+	checks map[string]HealthCheck
+}
+
+func NewHealthHandler(checks map[string]HealthCheck) *HealthHandler {
+	return &HealthHandler{checks: checks}
+}
+
+// ServeHTTP runs all checks, responding with 503 when any of them fails
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusOK
+	results := make(map[string]string, len(h.checks))
+	for name, check := range h.checks {
+		ctx, cancel := context.WithTimeout(r.Context(), HealthCheckTimeout)
+		err := check(ctx)
+		cancel()
+
+		results[name] = "ok"
+		if err != nil {
+			results[name] = err.Error()
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(results)
+}
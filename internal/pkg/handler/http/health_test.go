@@ -0,0 +1,56 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthHandler(t *testing.T) {
+	healthy := func(context.Context) error { return nil }
+	unhealthy := func(context.Context) error { return errors.New("database is unreachable") }
+
+	for _, tt := range []struct {
+		name     string
+		checks   map[string]HealthCheck
+		status   int
+		expected map[string]string
+	}{
+		{
+			name:     "healthy",
+			checks:   map[string]HealthCheck{"plans": healthy, "users": healthy},
+			status:   http.StatusOK,
+			expected: map[string]string{"plans": "ok", "users": "ok"},
+		},
+		{
+			name:     "unhealthy",
+			checks:   map[string]HealthCheck{"plans": healthy, "payments": unhealthy},
+			status:   http.StatusServiceUnavailable,
+			expected: map[string]string{"plans": "ok", "payments": "database is unreachable"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// prepare
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+
+			// test
+			NewHealthHandler(tt.checks).ServeHTTP(rec, req)
+
+			// verify
+			assert.Equal(t, tt.status, rec.Code)
+			var got map[string]string
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
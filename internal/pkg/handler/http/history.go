@@ -0,0 +1,43 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// HistoryHandler serves the audit trail of the records of a resource, at GET /{resource}/{id}/history
+type HistoryHandler struct {
+	audit    store.Audit
+	resource string
+}
+
+// NewHistoryHandler returns a new HistoryHandler for the records of the given resource, like "plan"
+func NewHistoryHandler(audit store.Audit, resource string) *HistoryHandler {
+	return &HistoryHandler{
+		audit:    audit,
+		resource: resource,
+	}
+}
+
+func (h *HistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	history, err := h.audit.History(r.Context(), h.resource, id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if len(history) == 0 {
+		writeError(w, r, fmt.Errorf("no history for %s %q: %w", h.resource, id, store.ErrNotFound))
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, struct {
+		History []*model.AuditEntry `json:"history"`
+	}{history})
+}
@@ -0,0 +1,73 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// unavailableError is returned when another service, needed to serve the request, can't be reached or failed
+type unavailableError struct {
+	service string
+}
+
+func (e *unavailableError) Error() string {
+	return fmt.Sprintf("the %s service is unavailable", e.service)
+}
+
+// reference is a record of another service that a record refers to in field
+type reference struct {
+	service string
+	field   string
+	url     string
+}
+
+// checkReferences returns a store.ValidationError when one of refs isn't found, or an unavailableError when its
+// service can't tell
+func checkReferences(ctx context.Context, refs ...reference) error {
+	for _, ref := range refs {
+		found, err := exists(ctx, ref.url)
+		if err != nil {
+			slog.Error("failed to look up a reference", "url", ref.url, "request_id", reqctx.RequestID(ctx), "error", err)
+			return &unavailableError{service: ref.service}
+		}
+		if !found {
+			return &store.ValidationError{Fields: []store.FieldError{{Field: ref.field, Message: "not found"}}}
+		}
+	}
+	return nil
+}
+
+// exists tells whether the record at url, served by another service, exists. The request ID and actor are
+// passed along. An error means the service couldn't tell, because it's unreachable or failed.
+func exists(ctx context.Context, url string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	for name, value := range reqctx.Headers(ctx) {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+)
+
+// AccessLog logs each request once served, with its status, latency and the size of the response body
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := wrap(w)
+
+		next.ServeHTTP(rw, r)
+
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		slog.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", rw.bytes,
+			"latency", time.Since(start),
+			"request_id", reqctx.RequestID(r.Context()),
+		)
+	})
+}
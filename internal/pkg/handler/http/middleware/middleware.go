@@ -0,0 +1,27 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+// Package middleware has the HTTP middlewares shared by the services: request IDs, panic recovery and access
+// logs. Default chains them in the order the services use.
+package middleware
+
+import (
+	"net/http"
+)
+
+// Middleware wraps a handler, adding behavior before and after it
+type Middleware func(next http.Handler) http.Handler
+
+// Chain wraps h with the middlewares, the first one being the outermost
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Default wraps h with the middlewares used by all the services: RequestID, AccessLog and Recover, so that
+// the access logs carry the request ID and report the requests that panicked
+func Default(h http.Handler) http.Handler {
+	return Chain(h, RequestID, AccessLog, Recover)
+}
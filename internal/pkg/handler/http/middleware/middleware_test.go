@@ -0,0 +1,162 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs makes slog write JSON records to the returned buffer until the test ends
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logRecords decodes the records written by captureLogs
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var record map[string]any
+		require.NoError(t, dec.Decode(&record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestChain(t *testing.T) {
+	// prepare
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := Chain(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { calls = append(calls, "handler") }), mw("first"), mw("second"))
+
+	// test
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// verify
+	assert.Equal(t, []string{"first", "second", "handler"}, calls)
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		seen = reqctx.RequestID(r.Context())
+	}))
+
+	t.Run("propagated", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(reqctx.RequestIDHeader, "req-1")
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, req)
+
+		assert.Equal(t, "req-1", seen)
+		assert.Equal(t, "req-1", w.Header().Get(reqctx.RequestIDHeader))
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.NotEmpty(t, seen)
+		assert.Equal(t, seen, w.Header().Get(reqctx.RequestIDHeader))
+	})
+}
+
+func TestDefault_Panic(t *testing.T) {
+	// prepare
+	logs := captureLogs(t)
+	h := Default(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		var sub *http.Response
+		_ = sub.StatusCode
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+	req.Header.Set(reqctx.RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+
+	// test
+	require.NotPanics(t, func() { h.ServeHTTP(w, req) })
+
+	// verify
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "req-1", w.Header().Get(reqctx.RequestIDHeader))
+
+	records := logRecords(t, logs)
+	require.Len(t, records, 2)
+	assert.Equal(t, "panic serving request", records[0]["msg"])
+	assert.Equal(t, "req-1", records[0]["request_id"])
+	assert.NotEmpty(t, records[0]["stack"])
+	assert.Equal(t, "request", records[1]["msg"])
+	assert.Equal(t, "req-1", records[1]["request_id"])
+	assert.EqualValues(t, http.StatusInternalServerError, records[1]["status"])
+}
+
+func TestRecover_AfterWrite(t *testing.T) {
+	// prepare
+	captureLogs(t)
+	h := Recover(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("boom")
+	}))
+	w := httptest.NewRecorder()
+
+	// test
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// verify: the status already sent is kept
+	assert.Equal(t, http.StatusAccepted, w.Code)
+}
+
+func TestRecover_AbortHandler(t *testing.T) {
+	h := Recover(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic(http.ErrAbortHandler) }))
+
+	assert.PanicsWithError(t, http.ErrAbortHandler.Error(), func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestAccessLog(t *testing.T) {
+	// prepare
+	logs := captureLogs(t)
+	h := Default(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+	}))
+	w := httptest.NewRecorder()
+
+	// test
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/plans", nil))
+
+	// verify
+	assert.True(t, w.Flushed)
+	records := logRecords(t, logs)
+	require.Len(t, records, 1)
+	assert.Equal(t, "POST", records[0]["method"])
+	assert.Equal(t, "/plans", records[0]["path"])
+	assert.EqualValues(t, http.StatusCreated, records[0]["status"])
+	assert.EqualValues(t, 5, records[0]["bytes"])
+	assert.Contains(t, records[0], "latency")
+	assert.NotEmpty(t, records[0]["request_id"])
+}
@@ -0,0 +1,46 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	handlerhttp "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/handler/http"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+)
+
+// Recover turns the panics of the handlers into 500 responses, logging them along with the stack. Panics with
+// http.ErrAbortHandler are left to the server, which aborts the response without logging.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := wrap(w)
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(p)
+			}
+
+			slog.Error("panic serving request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"request_id", reqctx.RequestID(r.Context()),
+				"panic", p,
+				"stack", string(debug.Stack()),
+			)
+			// the response can't be changed anymore once its header was written
+			if rw.status == 0 {
+				handlerhttp.WriteProblem(rw, handlerhttp.NewProblem(r, http.StatusInternalServerError,
+					"The request failed, the request ID identifies it in the logs"))
+			}
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
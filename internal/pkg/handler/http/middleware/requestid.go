@@ -0,0 +1,27 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// RequestID makes the ID and actor of the requests, from the X-Request-ID and X-Actor headers, available to
+// the handlers through reqctx. Requests without an ID are given a new one. The ID is returned in the response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := reqctx.FromHeaders(r.Context(), r.Header.Get)
+		id := reqctx.RequestID(ctx)
+		if id == "" {
+			id = store.NewID()
+			ctx = reqctx.WithRequestID(ctx, id)
+		}
+		w.Header().Set(reqctx.RequestIDHeader, id)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
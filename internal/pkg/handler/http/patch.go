@@ -0,0 +1,155 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/validate"
+)
+
+// MergePatchContentType is the content type of the PATCH requests, as defined by RFC 7396. Requests sent as
+// application/json are accepted too.
+const MergePatchContentType = "application/merge-patch+json"
+
+// serverFields are the JSON fields assigned by the server, which patches can't change
+var serverFields = []string{"id", "version", "created_at", "updated_at", "deleted_at"}
+
+// patchStore is the part of the store interfaces used by patches
+type patchStore[T any] interface {
+	Get(ctx context.Context, id string) (*T, error)
+	Update(ctx context.Context, record *T) (*T, error)
+}
+
+// patch applies the merge patch in the request body to the record {id}, serving PATCH /{resource}/{id}. The
+// server fields in the patch are ignored, and the patched record is validated as a whole, along with the
+// references the patch changed, when refs is set. The record is updated only if it's still at the version the
+// patch was applied to, or else the request fails with 412.
+func patch[T any](w http.ResponseWriter, r *http.Request, st patchStore[T], version func(*T) int64, refs func(*T) []reference) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != MergePatchContentType && mediaType != "application/json" {
+		WriteProblem(w, NewProblem(r, http.StatusUnsupportedMediaType,
+			fmt.Sprintf("The patch must be sent as %s", MergePatchContentType)))
+		return
+	}
+
+	var changes map[string]any
+	if err := decode(r, &changes); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if changes == nil {
+		writeError(w, r, fmt.Errorf("the patch must be a JSON object: %w", store.ErrInvalid))
+		return
+	}
+	for _, field := range serverFields {
+		delete(changes, field)
+	}
+
+	expected, err := ifMatch(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	current, err := st.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if expected != 0 && expected != version(current) {
+		writeError(w, r, fmt.Errorf("the record is at version %d: %w", version(current), store.ErrVersionMismatch))
+		return
+	}
+
+	patched, err := applyPatch(current, changes)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validate.Record(patched); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if refs != nil {
+		if err := checkReferences(r.Context(), changedReferences(refs(current), refs(patched))...); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	// the patched record keeps the version it was read at
+	updated, err := st.Update(r.Context(), patched)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, version(updated))
+	writeJSON(w, r, http.StatusOK, updated)
+}
+
+// changedReferences returns the references in after that aren't in before
+func changedReferences(before, after []reference) []reference {
+	var changed []reference
+	for _, ref := range after {
+		if !slices.Contains(before, ref) {
+			changed = append(changed, ref)
+		}
+	}
+	return changed
+}
+
+// applyPatch returns a copy of record with the merge patch applied
+func applyPatch[T any](record *T, changes map[string]any) (*T, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	data, err = json.Marshal(mergePatch(doc, changes))
+	if err != nil {
+		return nil, err
+	}
+	patched := new(T)
+	if err := decodeError(json.Unmarshal(data, patched)); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// mergePatch applies patch to target, as described by RFC 7396: the members of patch objects replace the ones
+// of target, recursively, and members set to null are removed. Any other patch replaces target.
+func mergePatch(target, patch any) any {
+	changes, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	doc, ok := target.(map[string]any)
+	if !ok {
+		doc = map[string]any{}
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(doc, name)
+			continue
+		}
+		doc[name] = mergePatch(doc[name], value)
+	}
+	return doc
+}
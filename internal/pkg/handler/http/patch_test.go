@@ -0,0 +1,169 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	// the examples of RFC 7396, appendix A
+	for _, tc := range []struct {
+		target, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	} {
+		var target, patch any
+		require.NoError(t, json.Unmarshal([]byte(tc.target), &target))
+		require.NoError(t, json.Unmarshal([]byte(tc.patch), &patch))
+
+		merged, err := json.Marshal(mergePatch(target, patch))
+
+		require.NoError(t, err)
+		assert.JSONEq(t, tc.expected, string(merged), "%s patched with %s", tc.target, tc.patch)
+	}
+}
+
+func TestPlanHandler_Patch(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	s := memory.NewPlanStore()
+	original, err := s.Create(ctx, &model.Plan{ID: "plan-1", Name: "Basic", Price: 10, Description: "The basic plan"})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH /plans/{id}", NewPlanHandler(s).Patch)
+	send := func(path, contentType, ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("patched", func(t *testing.T) {
+		// test
+		w := send("/plans/plan-1", MergePatchContentType, `"1"`,
+			`{"description":"Cheap","id":"plan-2","version":7,"created_at":"2020-01-01T00:00:00Z"}`)
+
+		// verify
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+		var patched model.Plan
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&patched))
+		assert.Equal(t, "plan-1", patched.ID, "server fields are ignored")
+		assert.Equal(t, "Basic", patched.Name)
+		assert.Equal(t, int32(10), patched.Price)
+		assert.Equal(t, "Cheap", patched.Description)
+		assert.Equal(t, int32(2), patched.Version)
+		assert.True(t, original.CreatedAt.Equal(patched.CreatedAt))
+	})
+
+	t.Run("field removed", func(t *testing.T) {
+		w := send("/plans/plan-1", "application/json", "", `{"description":null}`)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		plan, err := s.Get(ctx, "plan-1")
+		require.NoError(t, err)
+		assert.Empty(t, plan.Description)
+		assert.Equal(t, int32(10), plan.Price)
+	})
+
+	for _, tc := range []struct {
+		name        string
+		path        string
+		contentType string
+		ifMatch     string
+		body        string
+		expected    int
+	}{
+		{name: "invalid result", path: "/plans/plan-1", contentType: MergePatchContentType, body: `{"name":null,"price":-1}`, expected: http.StatusBadRequest},
+		{name: "field of the wrong type", path: "/plans/plan-1", contentType: MergePatchContentType, body: `{"price":"ten"}`, expected: http.StatusBadRequest},
+		{name: "not an object", path: "/plans/plan-1", contentType: MergePatchContentType, body: `null`, expected: http.StatusBadRequest},
+		{name: "stale version", path: "/plans/plan-1", contentType: MergePatchContentType, ifMatch: `"1"`, body: `{"price":5}`, expected: http.StatusPreconditionFailed},
+		{name: "missing", path: "/plans/plan-2", contentType: MergePatchContentType, body: `{"price":5}`, expected: http.StatusNotFound},
+		{name: "unsupported content type", path: "/plans/plan-1", contentType: "text/plain", body: `{"price":5}`, expected: http.StatusUnsupportedMediaType},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := send(tc.path, tc.contentType, tc.ifMatch, tc.body)
+			assert.Equal(t, tc.expected, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestSubscriptionHandler_Patch(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	s := memory.NewSubscriptionStore()
+	_, err := s.Create(ctx, &model.Subscription{ID: "sub-1", UserID: "user-1", PlanID: "plan-1"})
+	require.NoError(t, err)
+
+	// the users service knows user-1 and user-2, while the plans service fails
+	users := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/user-1") && !strings.HasSuffix(r.URL.Path, "/user-2") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer users.Close()
+	plans := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer plans.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH /subscriptions/{id}", NewSubscriptionHandler(s, users.URL, plans.URL).Patch)
+
+	for _, tc := range []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{name: "unknown user", body: `{"user_id":"user-3"}`, expected: http.StatusBadRequest},
+		{name: "changed plan", body: `{"plan_id":"plan-2"}`, expected: http.StatusBadGateway},
+		{name: "only the changed references are looked up", body: `{"user_id":"user-2"}`, expected: http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// test
+			req := httptest.NewRequest(http.MethodPatch, "/subscriptions/sub-1", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", MergePatchContentType)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			// verify
+			assert.Equal(t, tc.expected, w.Code, w.Body.String())
+		})
+	}
+
+	subscription, err := s.Get(ctx, "sub-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-2", subscription.UserID)
+	assert.Equal(t, "plan-1", subscription.PlanID)
+}
@@ -6,133 +6,213 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
-	"github.com/nats-io/nats.go"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/validate"
 	"github.com/nats-io/nats.go/jetstream"
 )
 
-// PaymentHandler is an HTTP handler that performs CRUD operations for model.Payment using a store.Payment
+// PaymentHandler is an HTTP handler that performs CRUD operations for model.Payment using a store.Payment.
+// New payments are written to an outbox, to be published to the subject and stored once consumed.
 type PaymentHandler struct {
 	store                 store.Payment
-	js                    jetstream.JetStream
-	jsSubject             string
+	outbox                store.Outbox
+	subject               string
 	subscriptionsEndpoint string
 }
 
 // NewPaymentHandler returns a new PaymentHandler
-func NewPaymentHandler(store store.Payment, js jetstream.JetStream, jsSubject string, subscriptionsEndpoint string) *PaymentHandler {
+func NewPaymentHandler(store store.Payment, outbox store.Outbox, subject string, subscriptionsEndpoint string) *PaymentHandler {
 	return &PaymentHandler{
 		store:                 store,
-		js:                    js,
-		jsSubject:             jsSubject,
+		outbox:                outbox,
+		subject:               subject,
 		subscriptionsEndpoint: subscriptionsEndpoint,
 	}
 }
 
 func (h *PaymentHandler) List(w http.ResponseWriter, r *http.Request) {
-	payments, err := h.store.List(r.Context())
+	ctx, err := readContext(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(payments)
+	opts, err := listOptions(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+
+	payments, next, err := h.store.List(ctx, opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, struct {
+		Payments   []*model.Payment `json:"payments"`
+		NextCursor string           `json:"next_cursor,omitempty"`
+	}{payments, next})
 }
 
 func (h *PaymentHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var payment model.Payment
-	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if err := decode(r, &payment); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validate.Record(&payment); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	// Check if subscription exists
-	sub, _ := http.Get(h.subscriptionsEndpoint + "/" + payment.SubscriptionID)
-	if sub.StatusCode != http.StatusOK {
-		http.Error(w, "Subscription not found", http.StatusBadRequest)
+	// the payment is stored asynchronously, so the server fields are assigned here, letting the client know the ID
+	payment.ClearServerFields()
+	payment.ID = store.NewID()
+	payment.CreatedAt = store.Now()
+	payment.UpdatedAt = payment.CreatedAt
+
+	if err := checkReferences(r.Context(), paymentReferences(h.subscriptionsEndpoint, &payment)...); err != nil {
+		writeError(w, r, err)
 		return
 	}
-	defer sub.Body.Close()
 
 	payload, err := json.Marshal(payment)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	_, err = h.js.PublishMsgAsync(&nats.Msg{
-		Subject: h.jsSubject,
+	// the request details travel with the message, so that they are recorded along with the payment
+	err = h.outbox.Add(r.Context(), &model.OutboxMessage{
+		Subject: h.subject,
+		Headers: reqctx.Headers(r.Context()),
 		Data:    payload,
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	err = json.NewEncoder(w).Encode(payment)
+	writeJSON(w, r, http.StatusCreated, payment)
+}
+
+func (h *PaymentHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx, err := readContext(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
-}
 
-func (h *PaymentHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	payment, err := h.store.Get(r.Context(), id)
+	payment, err := h.store.Get(ctx, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	if payment == nil {
-		http.Error(w, "Payment not found", http.StatusNotFound)
+	setETag(w, payment.Version)
+	writeJSON(w, r, http.StatusOK, payment)
+}
+
+// Update replaces the payment {id}, serving PUT /payments/{id}, once its subscription is found. With
+// If-None-Match: *, a payment is created with the ID instead, and stored right away.
+func (h *PaymentHandler) Update(w http.ResponseWriter, r *http.Request) {
+	payment := &model.Payment{}
+	if err := decode(r, payment); err != nil {
+		writeError(w, r, err)
 		return
 	}
-
-	err = json.NewEncoder(w).Encode(payment)
+	if err := validate.Record(payment); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := pathID(r, payment.ID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
+		return
+	}
+	if err := checkReferences(r.Context(), paymentReferences(h.subscriptionsEndpoint, payment)...); err != nil {
+		writeError(w, r, err)
 		return
 	}
-}
 
-func (h *PaymentHandler) Update(w http.ResponseWriter, r *http.Request) {
-	payment := &model.Payment{}
-	if err := json.NewDecoder(r.Body).Decode(payment); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if ifNoneMatchAny(r) {
+		payment.ClearServerFields()
+		payment.ID = id
+		createAt(w, r, h.store, payment, func(p *model.Payment) int64 { return p.Version })
 		return
 	}
 
-	_, err := h.store.Update(r.Context(), payment)
+	version, err := ifMatch(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+	payment.ID = id
+	if version != 0 {
+		payment.Version = version
+	}
 
-	err = json.NewEncoder(w).Encode(payment)
+	updated, err := h.store.Update(r.Context(), payment)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+
+	setETag(w, updated.Version)
+	writeJSON(w, r, http.StatusOK, updated)
+}
+
+// Patch applies a JSON merge patch to a payment, serving PATCH /payments/{id}
+func (h *PaymentHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	patch(w, r, h.store, func(p *model.Payment) int64 { return p.Version }, func(p *model.Payment) []reference {
+		return paymentReferences(h.subscriptionsEndpoint, p)
+	})
 }
 
 func (h *PaymentHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	err := h.store.Delete(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 }
 
+// Restore undeletes a payment, serving POST /payments/{id}:restore
+func (h *PaymentHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, ok := customMethod(r, "restore")
+	if !ok {
+		writeError(w, r, fmt.Errorf("no such method: %w", store.ErrNotFound))
+		return
+	}
+
+	restored, err := h.store.Restore(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, restored.Version)
+	writeJSON(w, r, http.StatusOK, restored)
+}
+
+// paymentReferences returns the reference of payment to its subscription
+func paymentReferences(subscriptionsEndpoint string, payment *model.Payment) []reference {
+	return []reference{
+		{service: "subscriptions", field: "subscription_id", url: subscriptionsEndpoint + "/" + url.PathEscape(payment.SubscriptionID)},
+	}
+}
+
 func (h *PaymentHandler) OnMessage(msg jetstream.Msg) {
 	payment := &model.Payment{}
 	err := json.Unmarshal(msg.Data(), payment)
@@ -140,7 +220,19 @@ func (h *PaymentHandler) OnMessage(msg jetstream.Msg) {
 		return
 	}
 
-	_, err = h.store.Create(context.Background(), payment)
+	ctx := reqctx.FromHeaders(context.Background(), msg.Headers().Get)
+	if err := validate.Record(payment); err != nil {
+		// published by an older version, or by another client
+		slog.Error("discarding invalid payment", "id", payment.ID, "request_id", reqctx.RequestID(ctx), "error", err)
+		_ = msg.Term()
+		return
+	}
+	_, err = h.store.Create(ctx, payment)
+	if errors.Is(err, store.ErrInvalid) || errors.Is(err, store.ErrConflict) {
+		// redelivering the message won't make it succeed
+		_ = msg.Term()
+		return
+	}
 	if err != nil {
 		return
 	}
@@ -4,11 +4,12 @@
 package http
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/validate"
 )
 
 // PlanHandler is an HTTP handler that performs CRUD operations for model.Plan using a store.Plan
@@ -24,79 +25,143 @@ func NewPlanHandler(store store.Plan) *PlanHandler {
 }
 
 func (h *PlanHandler) List(w http.ResponseWriter, r *http.Request) {
-	plans, err := h.store.List(r.Context())
+	ctx, err := readContext(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(plans)
+	opts, err := listOptions(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+
+	plans, next, err := h.store.List(ctx, opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, struct {
+		Plans      []*model.Plan `json:"plans"`
+		NextCursor string        `json:"next_cursor,omitempty"`
+	}{plans, next})
 }
 
 func (h *PlanHandler) Create(w http.ResponseWriter, r *http.Request) {
 	plan := &model.Plan{}
-	if err := json.NewDecoder(r.Body).Decode(plan); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if err := decode(r, plan); err != nil {
+		writeError(w, r, err)
 		return
 	}
-
-	created, err := h.store.Create(r.Context(), plan)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	plan.ClearServerFields()
+	if err := validate.Record(plan); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(created)
+	created, err := h.store.Create(r.Context(), plan)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+
+	setETag(w, int64(created.Version))
+	writeJSON(w, r, http.StatusOK, created)
 }
 
 func (h *PlanHandler) Get(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	plan, err := h.store.Get(r.Context(), id)
+	ctx, err := readContext(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(plan)
+	id := r.PathValue("id")
+	plan, err := h.store.Get(ctx, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+
+	setETag(w, int64(plan.Version))
+	writeJSON(w, r, http.StatusOK, plan)
 }
 
+// Update replaces the plan {id}, serving PUT /plans/{id}. With If-None-Match: *, a plan is created with the ID
+// instead.
 func (h *PlanHandler) Update(w http.ResponseWriter, r *http.Request) {
 	plan := &model.Plan{}
-	if err := json.NewDecoder(r.Body).Decode(plan); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if err := decode(r, plan); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validate.Record(plan); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := pathID(r, plan.ID)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	updated, err := h.store.Update(r.Context(), plan)
+	if ifNoneMatchAny(r) {
+		plan.ClearServerFields()
+		plan.ID = id
+		createAt(w, r, h.store, plan, func(p *model.Plan) int64 { return int64(p.Version) })
+		return
+	}
+
+	version, err := ifMatch(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+	plan.ID = id
+	if version != 0 {
+		plan.Version = int32(version)
+	}
 
-	err = json.NewEncoder(w).Encode(updated)
+	updated, err := h.store.Update(r.Context(), plan)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+
+	setETag(w, int64(updated.Version))
+	writeJSON(w, r, http.StatusOK, updated)
+}
+
+// Patch applies a JSON merge patch to a plan, serving PATCH /plans/{id}
+func (h *PlanHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	patch(w, r, h.store, func(p *model.Plan) int64 { return int64(p.Version) }, nil)
 }
 
 func (h *PlanHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	err := h.store.Delete(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 }
+
+// Restore undeletes a plan, serving POST /plans/{id}:restore
+func (h *PlanHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, ok := customMethod(r, "restore")
+	if !ok {
+		writeError(w, r, fmt.Errorf("no such method: %w", store.ErrNotFound))
+		return
+	}
+
+	restored, err := h.store.Restore(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, int64(restored.Version))
+	writeJSON(w, r, http.StatusOK, restored)
+}
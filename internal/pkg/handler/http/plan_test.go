@@ -0,0 +1,179 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanHandler_StatusCodes(t *testing.T) {
+	// prepare
+	store := memory.NewPlanStore()
+	_, err := store.Create(context.Background(), &model.Plan{ID: "123", Name: "Test Plan"})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	h := NewPlanHandler(store)
+	mux.HandleFunc("POST /plans", h.Create)
+	mux.HandleFunc("GET /plans/{id}", h.Get)
+	mux.HandleFunc("PUT /plans/{id}", h.Update)
+	mux.HandleFunc("DELETE /plans/{id}", h.Delete)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{name: "get existing", method: http.MethodGet, path: "/plans/123", expected: http.StatusOK},
+		{name: "get missing", method: http.MethodGet, path: "/plans/456", expected: http.StatusNotFound},
+		{name: "create ignoring the client id", method: http.MethodPost, path: "/plans", body: `{"id":"123","name":"Basic"}`, expected: http.StatusOK},
+		{name: "create malformed", method: http.MethodPost, path: "/plans", body: `{"name":`, expected: http.StatusBadRequest},
+		{name: "update missing", method: http.MethodPut, path: "/plans/456", body: `{"id":"456","name":"Basic"}`, expected: http.StatusNotFound},
+		{name: "create invalid", method: http.MethodPost, path: "/plans", body: `{"price":-1}`, expected: http.StatusBadRequest},
+		{name: "delete missing", method: http.MethodDelete, path: "/plans/456", expected: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// test
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			// verify
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
+
+func TestPlanHandler_CreateAssignsServerFields(t *testing.T) {
+	// prepare
+	h := NewPlanHandler(memory.NewPlanStore())
+	body := `{"id":"123","name":"Test Plan","version":7,"created_at":"2000-01-01T00:00:00Z"}`
+
+	// test
+	w := httptest.NewRecorder()
+	h.Create(w, httptest.NewRequest(http.MethodPost, "/plans", strings.NewReader(body)))
+
+	// verify
+	require.Equal(t, http.StatusOK, w.Code)
+	created := &model.Plan{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), created))
+	assert.NotEqual(t, "123", created.ID)
+	assert.NotEmpty(t, created.ID)
+	assert.Equal(t, "Test Plan", created.Name)
+	assert.EqualValues(t, 1, created.Version)
+	assert.Greater(t, created.CreatedAt.Year(), 2000)
+	assert.Equal(t, created.CreatedAt, created.UpdatedAt)
+}
+
+func TestPlanHandler_SoftDelete(t *testing.T) {
+	// prepare
+	store := memory.NewPlanStore()
+	_, err := store.Create(context.Background(), &model.Plan{ID: "123", Name: "Test Plan"})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	h := NewPlanHandler(store)
+	mux.HandleFunc("GET /plans/{id}", h.Get)
+	mux.HandleFunc("DELETE /plans/{id}", h.Delete)
+	mux.HandleFunc("POST /plans/{id}", h.Restore)
+	serve := func(method, path string) int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+
+	// test and verify
+	assert.Equal(t, http.StatusOK, serve(http.MethodDelete, "/plans/123"))
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/plans/123"))
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/plans/123?include_deleted=true"))
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/plans/123?include_deleted=maybe"))
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/plans/123"))
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/plans/123:restore"))
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/plans/123"))
+}
+
+func TestPlanHandler_IfMatch(t *testing.T) {
+	// prepare
+	store := memory.NewPlanStore()
+	_, err := store.Create(context.Background(), &model.Plan{ID: "123", Name: "Test Plan"})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	h := NewPlanHandler(store)
+	mux.HandleFunc("GET /plans/{id}", h.Get)
+	mux.HandleFunc("PUT /plans/{id}", h.Update)
+	put := func(ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/plans/123", strings.NewReader(`{"id":"123","name":"Updated"}`))
+		req.Header.Set("If-Match", ifMatch)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// test
+	get := httptest.NewRecorder()
+	mux.ServeHTTP(get, httptest.NewRequest(http.MethodGet, "/plans/123", nil))
+	first := put(get.Header().Get("ETag"))
+	second := put(get.Header().Get("ETag"))
+	malformed := put("not-a-version")
+
+	// verify
+	assert.Equal(t, `"1"`, get.Header().Get("ETag"))
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, `"2"`, first.Header().Get("ETag"))
+	assert.Equal(t, http.StatusPreconditionFailed, second.Code)
+	assert.Equal(t, http.StatusBadRequest, malformed.Code)
+}
+
+func TestPlanHandler_ListPages(t *testing.T) {
+	// prepare
+	store := memory.NewPlanStore()
+	for _, id := range []string{"1", "2", "3"} {
+		_, err := store.Create(context.Background(), &model.Plan{ID: id})
+		require.NoError(t, err)
+	}
+	h := NewPlanHandler(store)
+	list := func(query string) (*httptest.ResponseRecorder, listedPlans) {
+		w := httptest.NewRecorder()
+		h.List(w, httptest.NewRequest(http.MethodGet, "/plans?"+query, nil))
+		var resp listedPlans
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	// test
+	_, first := list("page_size=2&sort=-id")
+	_, second := list("page_size=2&sort=-id&cursor=" + first.NextCursor)
+	unknown, _ := list("password=secret")
+	invalid, _ := list("page_size=many")
+
+	// verify
+	require.Len(t, first.Plans, 2)
+	assert.Equal(t, "3", first.Plans[0].ID)
+	assert.NotEmpty(t, first.NextCursor)
+	require.Len(t, second.Plans, 1)
+	assert.Equal(t, "1", second.Plans[0].ID)
+	assert.Empty(t, second.NextCursor)
+	assert.Equal(t, http.StatusBadRequest, unknown.Code)
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+}
+
+type listedPlans struct {
+	Plans      []*model.Plan `json:"plans"`
+	NextCursor string        `json:"next_cursor"`
+}
@@ -0,0 +1,166 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// ProblemContentType is the content type of the error responses
+const ProblemContentType = "application/problem+json"
+
+// errPreconditionFailed is returned when a condition of the request headers, like If-None-Match, isn't met
+var errPreconditionFailed = errors.New("precondition failed")
+
+// Problem is an error response, as described by RFC 7807. The Type of the problems is "about:blank", the
+// Title being the text of the status code and Detail telling what went wrong.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// RequestID identifies the request in the logs
+	RequestID string `json:"request_id,omitempty"`
+	// Errors tells which fields of the request are invalid, and why
+	Errors []FieldError `json:"errors,omitempty"`
+	// ConflictingID is the ID of the record the request clashed with, for conflicts
+	ConflictingID string `json:"conflicting_id,omitempty"`
+}
+
+// FieldError tells why the value of a field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// NewProblem returns the problem with the given status, for the request r
+func NewProblem(r *http.Request, status int, detail string) *Problem {
+	return &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: reqctx.RequestID(r.Context()),
+	}
+}
+
+// WriteProblem writes p to the response
+func WriteProblem(w http.ResponseWriter, p *Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// writeError writes err to the response as a problem, with the status code matching the store error it wraps.
+// Errors not wrapping a store error are logged and reported without details, which could reveal internals.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := statusCode(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("failed to serve request", "method", r.Method, "path", r.URL.Path,
+			"request_id", reqctx.RequestID(r.Context()), "error", err)
+		WriteProblem(w, NewProblem(r, status, "The request failed, the request ID identifies it in the logs"))
+		return
+	}
+
+	p := NewProblem(r, status, err.Error())
+	var conflict *store.ConflictError
+	if errors.As(err, &conflict) {
+		p.ConflictingID = conflict.ID
+	}
+	var invalid *store.ValidationError
+	if errors.As(err, &invalid) {
+		for _, f := range invalid.Fields {
+			p.Errors = append(p.Errors, FieldError{Field: f.Field, Message: f.Message})
+		}
+	}
+	WriteProblem(w, p)
+}
+
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, store.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, store.ErrVersionMismatch), errors.Is(err, errPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.As(err, new(*unavailableError)):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeJSON writes v to the response as JSON, with the given status code. Values that can't be encoded are
+// written as an error instead.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
+}
+
+// decode reads the JSON request body into v. Fields of the wrong type are reported as store.ValidationError,
+// other malformed bodies as store.ErrInvalid.
+func decode(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	// numbers decoded into interfaces, like the ones of merge patches, keep their precision
+	dec.UseNumber()
+	return decodeError(dec.Decode(v))
+}
+
+// decodeError converts the errors of encoding/json into store errors, as described by decode
+func decodeError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &store.ValidationError{Fields: []store.FieldError{{
+			Field:   typeErr.Field,
+			Message: fmt.Sprintf("must be a JSON %s", jsonType(typeErr.Type)),
+		}}}
+	}
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid request payload: empty body: %w", store.ErrInvalid)
+	}
+	return fmt.Errorf("invalid request payload: %s: %w", err, store.ErrInvalid)
+}
+
+// jsonType returns the JSON type Go values of type t are encoded as
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteError(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		body     string
+		expected Problem
+	}{
+		{
+			name: "malformed payload",
+			body: `{"name":`,
+			expected: Problem{
+				Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest,
+				Detail: "invalid request payload: unexpected EOF: invalid", Instance: "/plans", RequestID: "req-1",
+			},
+		},
+		{
+			name: "field of the wrong type",
+			body: `{"name":"Basic","price":"ten"}`,
+			expected: Problem{
+				Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest,
+				Detail: "price must be a JSON number: invalid", Instance: "/plans", RequestID: "req-1",
+				Errors: []FieldError{{Field: "price", Message: "must be a JSON number"}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// prepare
+			h := NewPlanHandler(memory.NewPlanStore())
+			req := httptest.NewRequest(http.MethodPost, "/plans", strings.NewReader(tc.body))
+			req = req.WithContext(reqctx.WithRequestID(req.Context(), "req-1"))
+			w := httptest.NewRecorder()
+
+			// test
+			h.Create(w, req)
+
+			// verify
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+			var problem Problem
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+			assert.Equal(t, tc.expected, problem)
+		})
+	}
+}
+
+func TestWriteError_Internal(t *testing.T) {
+	// prepare
+	req := httptest.NewRequest(http.MethodGet, "/plans", nil)
+	w := httptest.NewRecorder()
+
+	// test
+	writeError(w, req, errors.New("dial tcp 10.0.0.1:5432: connection refused"))
+
+	// verify: the details stay in the logs
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "10.0.0.1")
+}
@@ -0,0 +1,103 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// readContext returns the context for store reads, honoring the include_deleted query parameter
+func readContext(r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+
+	value := r.URL.Query().Get("include_deleted")
+	if value == "" {
+		return ctx, nil
+	}
+
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include_deleted parameter %q: %w", value, store.ErrInvalid)
+	}
+	if include {
+		ctx = store.WithDeleted(ctx)
+	}
+	return ctx, nil
+}
+
+// customMethod extracts the ID from paths like /plans/{id}:restore, as the wildcards in http.ServeMux
+// patterns have to span the whole path segment. It returns false when the path doesn't end with the method.
+func customMethod(r *http.Request, method string) (string, bool) {
+	id, ok := strings.CutSuffix(r.PathValue("id"), ":"+method)
+	return id, ok && id != ""
+}
+
+// pathID returns the {id} of the request path, which identifies the record to write. The ID in the body, when
+// given, must be the same.
+func pathID(r *http.Request, bodyID string) (string, error) {
+	id := r.PathValue("id")
+	if bodyID != "" && bodyID != id {
+		return "", &store.ValidationError{Fields: []store.FieldError{{Field: "id", Message: "must match the ID in the path"}}}
+	}
+	return id, nil
+}
+
+// listParameters are the query parameters with a meaning of their own in listings, every other parameter is a filter
+var listParameters = map[string]bool{
+	"page_size":       true,
+	"cursor":          true,
+	"sort":            true,
+	"created_after":   true,
+	"created_before":  true,
+	"include_deleted": true,
+}
+
+// listOptions builds the store.ListOptions from the query parameters, like in
+// ?page_size=10&sort=-created_at&user_id=123&created_after=2024-01-01T00:00:00Z
+func listOptions(r *http.Request) (store.ListOptions, error) {
+	query := r.URL.Query()
+	opts := store.ListOptions{
+		Cursor: query.Get("cursor"),
+		Sort:   query.Get("sort"),
+	}
+
+	if value := query.Get("page_size"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid page_size parameter %q: %w", value, store.ErrInvalid)
+		}
+		opts.PageSize = size
+	}
+
+	for param, dst := range map[string]*time.Time{"created_after": &opts.CreatedAfter, "created_before": &opts.CreatedBefore} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s parameter %q: %w", param, value, store.ErrInvalid)
+		}
+		*dst = t
+	}
+
+	for param := range query {
+		if listParameters[param] {
+			continue
+		}
+		if opts.Filters == nil {
+			opts.Filters = make(map[string]string)
+		}
+		opts.Filters[param] = query.Get(param)
+	}
+
+	return opts, nil
+}
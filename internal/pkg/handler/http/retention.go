@@ -0,0 +1,36 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/retention"
+)
+
+// RetentionHandler reports on the purges of a retention job, at GET /{resource}:retention: how many records
+// the job would purge if it ran now, and how many it purged the last time it ran
+type RetentionHandler struct {
+	job *retention.Job
+}
+
+// NewRetentionHandler returns a new RetentionHandler for the given job
+func NewRetentionHandler(job *retention.Job) *RetentionHandler {
+	return &RetentionHandler{
+		job: job,
+	}
+}
+
+func (h *RetentionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pending, err := h.job.DryRun(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, struct {
+		Pending *retention.Report `json:"pending"`
+		LastRun *retention.Report `json:"last_run,omitempty"`
+	}{pending, h.job.LastRun()})
+}
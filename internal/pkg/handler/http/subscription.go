@@ -4,11 +4,13 @@
 package http
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/validate"
 )
 
 // SubscriptionHandler is an HTTP handler that performs CRUD operations for model.Subscription using a store.Subscription
@@ -28,111 +30,164 @@ func NewSubscriptionHandler(store store.Subscription, usersEndpoint string, plan
 }
 
 func (h *SubscriptionHandler) List(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	ctx, err := readContext(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	subscriptions, err := h.store.List(r.Context())
+	opts, err := listOptions(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(subscriptions)
+	subscriptions, next, err := h.store.List(ctx, opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+
+	writeJSON(w, r, http.StatusOK, struct {
+		Subscriptions []*model.Subscription `json:"subscriptions"`
+		NextCursor    string                `json:"next_cursor,omitempty"`
+	}{subscriptions, next})
 }
 
 func (h *SubscriptionHandler) Create(w http.ResponseWriter, r *http.Request) {
 	subscription := &model.Subscription{}
-	if err := json.NewDecoder(r.Body).Decode(subscription); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if err := decode(r, subscription); err != nil {
+		writeError(w, r, err)
 		return
 	}
-
-	// verify the user exists
-	{
-		user, _ := http.Get(h.usersEndpoint + "/" + subscription.UserID)
-		if user.StatusCode != http.StatusOK {
-			http.Error(w, "User not found", http.StatusBadRequest)
-			return
-		}
-		defer user.Body.Close()
+	subscription.ClearServerFields()
+	if err := validate.Record(subscription); err != nil {
+		writeError(w, r, err)
+		return
 	}
 
-	// verify the plan exists
-	{
-		plan, _ := http.Get(h.plansEndpoint + "/" + subscription.PlanID)
-		if plan.StatusCode != http.StatusOK {
-			http.Error(w, "Plan not found", http.StatusBadRequest)
-			return
-		}
-		defer plan.Body.Close()
+	if err := checkReferences(r.Context(), subscriptionReferences(h.usersEndpoint, h.plansEndpoint, subscription)...); err != nil {
+		writeError(w, r, err)
+		return
 	}
 
 	created, err := h.store.Create(r.Context(), subscription)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(created)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	setETag(w, created.Version)
+	writeJSON(w, r, http.StatusOK, created)
+}
+
+// subscriptionReferences returns the references of subscription to its user and plan
+func subscriptionReferences(usersEndpoint, plansEndpoint string, subscription *model.Subscription) []reference {
+	return []reference{
+		{service: "users", field: "user_id", url: usersEndpoint + "/" + url.PathEscape(subscription.UserID)},
+		{service: "plans", field: "plan_id", url: plansEndpoint + "/" + url.PathEscape(subscription.PlanID)},
 	}
 }
 
 func (h *SubscriptionHandler) Get(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	subscription, err := h.store.Get(r.Context(), id)
+	ctx, err := readContext(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	if subscription == nil {
-		http.Error(w, "Subscription not found", http.StatusNotFound)
-		return
-	}
-
-	err = json.NewEncoder(w).Encode(subscription)
+	id := r.PathValue("id")
+	subscription, err := h.store.Get(ctx, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+
+	setETag(w, subscription.Version)
+	writeJSON(w, r, http.StatusOK, subscription)
 }
 
+// Update replaces the subscription {id}, serving PUT /subscriptions/{id}, once its user and plan are found.
+// With If-None-Match: *, a subscription is created with the ID instead.
 func (h *SubscriptionHandler) Update(w http.ResponseWriter, r *http.Request) {
 	subscription := &model.Subscription{}
-	if err := json.NewDecoder(r.Body).Decode(subscription); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if err := decode(r, subscription); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validate.Record(subscription); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := pathID(r, subscription.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := checkReferences(r.Context(), subscriptionReferences(h.usersEndpoint, h.plansEndpoint, subscription)...); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if ifNoneMatchAny(r) {
+		subscription.ClearServerFields()
+		subscription.ID = id
+		createAt(w, r, h.store, subscription, func(s *model.Subscription) int64 { return s.Version })
 		return
 	}
 
-	updatedSubscription, err := h.store.Update(r.Context(), subscription)
+	version, err := ifMatch(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+	subscription.ID = id
+	if version != 0 {
+		subscription.Version = int64(version)
+	}
 
-	err = json.NewEncoder(w).Encode(updatedSubscription)
+	updated, err := h.store.Update(r.Context(), subscription)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+
+	setETag(w, updated.Version)
+	writeJSON(w, r, http.StatusOK, updated)
+}
+
+// Patch applies a JSON merge patch to a subscription, serving PATCH /subscriptions/{id}
+func (h *SubscriptionHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	patch(w, r, h.store, func(s *model.Subscription) int64 { return s.Version }, func(s *model.Subscription) []reference {
+		return subscriptionReferences(h.usersEndpoint, h.plansEndpoint, s)
+	})
 }
 
 func (h *SubscriptionHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	err := h.store.Delete(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Restore undeletes a subscription, serving POST /subscriptions/{id}:restore
+func (h *SubscriptionHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, ok := customMethod(r, "restore")
+	if !ok {
+		writeError(w, r, fmt.Errorf("no such method: %w", store.ErrNotFound))
+		return
+	}
+
+	restored, err := h.store.Restore(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, restored.Version)
+	writeJSON(w, r, http.StatusOK, restored)
+}
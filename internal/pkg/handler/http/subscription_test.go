@@ -0,0 +1,117 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionHandler_Create(t *testing.T) {
+	// the users and plans services, telling which records exist
+	var requestIDs []string
+	service := func(id string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestIDs = append(requestIDs, r.Header.Get(reqctx.RequestIDHeader))
+			if !strings.HasSuffix(r.URL.Path, "/"+id) {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	}
+	users := service("user-1")
+	defer users.Close()
+	plans := service("plan-1")
+	defer plans.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	unreachable := httptest.NewServer(nil)
+	unreachable.Close()
+
+	for _, tc := range []struct {
+		name     string
+		users    string
+		body     string
+		expected int
+	}{
+		{name: "created", users: users.URL, body: `{"user_id":"user-1","plan_id":"plan-1"}`, expected: http.StatusOK},
+		{name: "unknown user", users: users.URL, body: `{"user_id":"user-2","plan_id":"plan-1"}`, expected: http.StatusBadRequest},
+		{name: "unknown plan", users: users.URL, body: `{"user_id":"user-1","plan_id":"plan-2"}`, expected: http.StatusBadRequest},
+		{name: "failing users service", users: failing.URL, body: `{"user_id":"user-1","plan_id":"plan-1"}`, expected: http.StatusBadGateway},
+		{name: "unreachable users service", users: unreachable.URL, body: `{"user_id":"user-1","plan_id":"plan-1"}`, expected: http.StatusBadGateway},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// prepare
+			h := NewSubscriptionHandler(memory.NewSubscriptionStore(), tc.users, plans.URL)
+			req := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(tc.body))
+			req = req.WithContext(reqctx.WithRequestID(req.Context(), "req-1"))
+			w := httptest.NewRecorder()
+
+			// test
+			require.NotPanics(t, func() { h.Create(w, req) })
+
+			// verify
+			assert.Equal(t, tc.expected, w.Code, w.Body.String())
+		})
+	}
+	assert.Contains(t, requestIDs, "req-1", "the request ID is passed along")
+}
+
+func TestSubscriptionHandler_CreateEscapesReferences(t *testing.T) {
+	// prepare
+	var paths []string
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer service.Close()
+	h := NewSubscriptionHandler(memory.NewSubscriptionStore(), service.URL+"/users", service.URL+"/plans")
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(`{"user_id":"../plans/plan-1?x=1","plan_id":"plan-1"}`))
+	w := httptest.NewRecorder()
+
+	// test
+	h.Create(w, req)
+
+	// verify
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []string{"/users/..%2Fplans%2Fplan-1%3Fx=1"}, paths)
+}
+
+func TestSubscriptionHandler_UpdateChecksReferences(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	s := memory.NewSubscriptionStore()
+	_, err := s.Create(ctx, &model.Subscription{ID: "sub-1", UserID: "user-1", PlanID: "plan-1"})
+	require.NoError(t, err)
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/user-2") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer service.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /subscriptions/{id}", NewSubscriptionHandler(s, service.URL, service.URL).Update)
+	req := httptest.NewRequest(http.MethodPut, "/subscriptions/sub-1", strings.NewReader(`{"user_id":"user-2","plan_id":"plan-1"}`))
+	w := httptest.NewRecorder()
+
+	// test
+	mux.ServeHTTP(w, req)
+
+	// verify
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	subscription, err := s.Get(ctx, "sub-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", subscription.UserID, "not updated")
+}
@@ -0,0 +1,48 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// createStore is the part of the store interfaces used by createAt
+type createStore[T any] interface {
+	Get(ctx context.Context, id string) (*T, error)
+	Create(ctx context.Context, record *T) (*T, error)
+}
+
+// createAt creates record, which has the ID of the request path, serving PUT /{resource}/{id} requests with
+// If-None-Match: *. The request fails with 412 when a record, even a deleted one, already has the ID, including
+// one created by a concurrent request.
+func createAt[T any](w http.ResponseWriter, r *http.Request, st createStore[T], record *T, version func(*T) int64) {
+	id := r.PathValue("id")
+	_, err := st.Get(store.WithDeleted(r.Context()), id)
+	switch {
+	case err == nil:
+		writeError(w, r, fmt.Errorf("record %q already exists: %w", id, errPreconditionFailed))
+		return
+	case !errors.Is(err, store.ErrNotFound):
+		writeError(w, r, err)
+		return
+	}
+
+	created, err := st.Create(r.Context(), record)
+	var conflict *store.ConflictError
+	if errors.As(err, &conflict) && conflict.ID == id {
+		err = fmt.Errorf("record %q already exists: %w", id, errPreconditionFailed)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, version(created))
+	writeJSON(w, r, http.StatusCreated, created)
+}
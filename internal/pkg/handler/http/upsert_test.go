@@ -0,0 +1,119 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserHandler_Update(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	s := memory.NewUserStore()
+	for _, id := range []string{"1", "2"} {
+		_, err := s.Create(ctx, &model.User{ID: id, Name: "User " + id, Email: id + "@example.com"})
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.Delete(ctx, "2"))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /users/{id}", NewUserHandler(s).Update)
+	put := func(path, ifNoneMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("the path identifies the record", func(t *testing.T) {
+		w := put("/users/1", "", `{"name":"John","email":"john@example.com"}`)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		user, err := s.Get(ctx, "1")
+		require.NoError(t, err)
+		assert.Equal(t, "John", user.Name)
+	})
+
+	t.Run("ID mismatch", func(t *testing.T) {
+		w := put("/users/1", "", `{"id":"3","name":"John","email":"john@example.com"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var problem Problem
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+		assert.Equal(t, []FieldError{{Field: "id", Message: "must match the ID in the path"}}, problem.Errors)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		w := put("/users/3", "", `{"name":"Mary","email":"mary@example.com"}`)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		_, err := s.Get(ctx, "3")
+		assert.Error(t, err, "not created")
+	})
+
+	t.Run("created with If-None-Match", func(t *testing.T) {
+		w := put("/users/3", "*", `{"id":"3","name":"Mary","email":"mary@example.com","version":7}`)
+
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+		user, err := s.Get(ctx, "3")
+		require.NoError(t, err)
+		assert.Equal(t, "Mary", user.Name)
+	})
+
+	for _, id := range []string{"1", "2"} {
+		t.Run("existing with If-None-Match "+id, func(t *testing.T) {
+			w := put("/users/"+id, "*", `{"name":"Mary","email":"mary.2@example.com"}`)
+
+			assert.Equal(t, http.StatusPreconditionFailed, w.Code, "even when deleted")
+		})
+	}
+}
+
+// racingStore is a createStore that doesn't see the records created by concurrent requests
+type racingStore[T any] struct {
+	createStore[T]
+}
+
+func (s racingStore[T]) Get(_ context.Context, id string) (*T, error) {
+	return nil, fmt.Errorf("record %q: %w", id, store.ErrNotFound)
+}
+
+func TestCreateAt_ConcurrentCreate(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	s := memory.NewUserStore()
+	_, err := s.Create(ctx, &model.User{ID: "1", Name: "John", Email: "john@example.com"})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user := &model.User{ID: r.PathValue("id"), Name: "Mary", Email: "mary@example.com"}
+		createAt(w, r, racingStore[model.User]{s}, user, func(u *model.User) int64 { return u.Version })
+	})
+	req := httptest.NewRequest(http.MethodPut, "/users/1", nil)
+	req.Header.Set("If-None-Match", "*")
+	w := httptest.NewRecorder()
+
+	// test
+	mux.ServeHTTP(w, req)
+
+	// verify
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code, w.Body.String())
+}
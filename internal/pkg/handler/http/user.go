@@ -4,11 +4,12 @@
 package http
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/validate"
 )
 
 // UserHandler is an HTTP handler that performs CRUD operations for model.User using a store.User
@@ -24,86 +25,145 @@ func NewUserHandler(store store.User) *UserHandler {
 }
 
 func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
-	users, err := h.store.List(r.Context())
+	ctx, err := readContext(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(users)
+	opts, err := listOptions(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+
+	users, next, err := h.store.List(ctx, opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, struct {
+		Users      []*model.User `json:"users"`
+		NextCursor string        `json:"next_cursor,omitempty"`
+	}{users, next})
 }
 
 func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 	user := &model.User{}
-	if err := json.NewDecoder(r.Body).Decode(user); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if err := decode(r, user); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	user.ClearServerFields()
+	if err := validate.Record(user); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	created, err := h.store.Create(r.Context(), user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(created)
+	setETag(w, created.Version)
+	writeJSON(w, r, http.StatusOK, created)
+}
+
+func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx, err := readContext(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
-}
 
-func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	user, err := h.store.Get(r.Context(), id)
+	user, err := h.store.Get(ctx, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	if user == nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+	setETag(w, user.Version)
+	writeJSON(w, r, http.StatusOK, user)
+}
+
+// Update replaces the user {id}, serving PUT /users/{id}. With If-None-Match: *, a user is created with the ID
+// instead.
+func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
+	user := &model.User{}
+	if err := decode(r, user); err != nil {
+		writeError(w, r, err)
 		return
 	}
-
-	err = json.NewEncoder(w).Encode(user)
+	if err := validate.Record(user); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := pathID(r, user.ID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
-}
 
-func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
-	user := &model.User{}
-	if err := json.NewDecoder(r.Body).Decode(user); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if ifNoneMatchAny(r) {
+		user.ClearServerFields()
+		user.ID = id
+		createAt(w, r, h.store, user, func(u *model.User) int64 { return u.Version })
 		return
 	}
 
-	updatedSubscription, err := h.store.Update(r.Context(), user)
+	version, err := ifMatch(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+	user.ID = id
+	if version != 0 {
+		user.Version = int64(version)
+	}
 
-	err = json.NewEncoder(w).Encode(updatedSubscription)
+	updated, err := h.store.Update(r.Context(), user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+
+	setETag(w, updated.Version)
+	writeJSON(w, r, http.StatusOK, updated)
+}
+
+// Patch applies a JSON merge patch to a user, serving PATCH /users/{id}
+func (h *UserHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	patch(w, r, h.store, func(u *model.User) int64 { return u.Version }, nil)
 }
 
 func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	err := h.store.Delete(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Restore undeletes a user, serving POST /users/{id}:restore
+func (h *UserHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, ok := customMethod(r, "restore")
+	if !ok {
+		writeError(w, r, fmt.Errorf("no such method: %w", store.ErrNotFound))
+		return
+	}
+
+	restored, err := h.store.Restore(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, restored.Version)
+	writeJSON(w, r, http.StatusOK, restored)
+}
@@ -0,0 +1,58 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserHandler_EmailConflict(t *testing.T) {
+	// prepare
+	store := memory.NewUserStore()
+	_, err := store.Create(context.Background(), &model.User{ID: "123", Email: "john@example.com"})
+	require.NoError(t, err)
+	h := NewUserHandler(store)
+
+	// test
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"John","email":"John@Example.com"}`))
+	w := httptest.NewRecorder()
+	h.Create(w, req)
+
+	// verify
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	var body Problem
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	assert.Equal(t, http.StatusConflict, body.Status)
+	assert.Equal(t, "123", body.ConflictingID)
+}
+
+func TestUserHandler_Invalid(t *testing.T) {
+	// prepare
+	h := NewUserHandler(memory.NewUserStore())
+
+	// test
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"email":"john"}`))
+	w := httptest.NewRecorder()
+	h.Create(w, req)
+
+	// verify
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var body Problem
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	assert.Equal(t, []FieldError{
+		{Field: "name", Message: "is required"},
+		{Field: "email", Message: "must be a valid email address"},
+	}, body.Errors)
+}
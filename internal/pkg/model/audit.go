@@ -0,0 +1,40 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The actions recorded in the audit trail
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+// AuditEntry records a change made to a record
+type AuditEntry struct {
+	ID string `json:"id"`
+	// Resource is the kind of the changed record, like "plan"
+	Resource   string `json:"resource"`
+	ResourceID string `json:"resource_id"`
+	Action     string `json:"action"`
+	// Actor is who the client making the change claimed to be, in X-Actor. It isn't authenticated, and only
+	// tells who made the change as far as the client can be trusted.
+	Actor     string    `json:"claimed_actor,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Changes are the fields changed, in the JSON encoding of the record
+	Changes []FieldChange `json:"changes"`
+}
+
+// FieldChange is the value of a field before and after a change. Before is empty for created records.
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
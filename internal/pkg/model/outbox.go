@@ -0,0 +1,22 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package model
+
+import "time"
+
+// OutboxMessage is a message waiting in the outbox to be published
+type OutboxMessage struct {
+	ID        string            `json:"id"`
+	Subject   string            `json:"subject"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Data      []byte            `json:"data"`
+	CreatedAt time.Time         `json:"created_at"`
+
+	// Attempts is the number of failed attempts to publish the message
+	Attempts int `json:"attempts"`
+	// NextAttemptAt is when the message can be published again, after a failed attempt
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
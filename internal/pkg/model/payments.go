@@ -5,13 +5,25 @@ package model
 
 import "time"
 
+// PaymentFailed is the status of the payments that failed
+const PaymentFailed = "failed"
+
 type Payment struct {
-	ID             string    `json:"id"`
-	SubscriptionID string    `json:"subscription_id"`
-	Amount         float64   `json:"amount"`
-	Status         string    `json:"status"`
-	Version        int64     `json:"version"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	DeletedAt      time.Time `json:"deleted_at"`
+	ID             string     `json:"id"`
+	SubscriptionID string     `json:"subscription_id" validate:"required,max=64"`
+	Amount         float64    `json:"amount" validate:"gt=0"`
+	Status         string     `json:"status" validate:"max=32"`
+	Version        int64      `json:"version"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ClearServerFields resets the fields assigned by the server, discarding the values sent by clients
+func (p *Payment) ClearServerFields() {
+	p.ID = ""
+	p.Version = 0
+	p.CreatedAt = time.Time{}
+	p.UpdatedAt = time.Time{}
+	p.DeletedAt = nil
 }
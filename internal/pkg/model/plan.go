@@ -6,12 +6,21 @@ package model
 import "time"
 
 type Plan struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Price       int32     `json:"price"`
-	Description string    `json:"description"`
-	Version     int32     `json:"version"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	DeletedAt   time.Time `json:"deleted_at"`
+	ID          string     `json:"id"`
+	Name        string     `json:"name" validate:"required,max=100"`
+	Price       int32      `json:"price" validate:"min=0"`
+	Description string     `json:"description" validate:"max=1000"`
+	Version     int32      `json:"version"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ClearServerFields resets the fields assigned by the server, discarding the values sent by clients
+func (p *Plan) ClearServerFields() {
+	p.ID = ""
+	p.Version = 0
+	p.CreatedAt = time.Time{}
+	p.UpdatedAt = time.Time{}
+	p.DeletedAt = nil
 }
@@ -6,11 +6,20 @@ package model
 import "time"
 
 type Subscription struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	PlanID    string    `json:"plan_id"`
-	Version   int64     `json:"version"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	DeletedAt time.Time `json:"deleted_at"`
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id" validate:"required,max=64"`
+	PlanID    string     `json:"plan_id" validate:"required,max=64"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ClearServerFields resets the fields assigned by the server, discarding the values sent by clients
+func (s *Subscription) ClearServerFields() {
+	s.ID = ""
+	s.Version = 0
+	s.CreatedAt = time.Time{}
+	s.UpdatedAt = time.Time{}
+	s.DeletedAt = nil
 }
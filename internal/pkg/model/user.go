@@ -7,11 +7,20 @@ import "time"
 
 // User represents a user in the system
 type User struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Version   int64     `json:"version"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	DeletedAt time.Time `json:"deleted_at"`
+	ID        string     `json:"id"`
+	Name      string     `json:"name" validate:"required,max=100"`
+	Email     string     `json:"email" validate:"required,email,max=254"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ClearServerFields resets the fields assigned by the server, discarding the values sent by clients
+func (u *User) ClearServerFields() {
+	u.ID = ""
+	u.Version = 0
+	u.CreatedAt = time.Time{}
+	u.UpdatedAt = time.Time{}
+	u.DeletedAt = nil
 }
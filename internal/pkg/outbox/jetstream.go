@@ -0,0 +1,34 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package outbox
+
+import (
+	"context"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type jetStreamPublisher struct {
+	js jetstream.JetStream
+}
+
+// NewJetStreamPublisher returns a publisher sending the messages to JetStream, identified by their ID so that the
+// stream drops the ones published more than once within its duplicate window
+func NewJetStreamPublisher(js jetstream.JetStream) Publisher {
+	return &jetStreamPublisher{js: js}
+}
+
+func (p *jetStreamPublisher) Publish(ctx context.Context, msg *model.OutboxMessage) error {
+	m := nats.NewMsg(msg.Subject)
+	m.Data = msg.Data
+	for k, v := range msg.Headers {
+		m.Header.Set(k, v)
+	}
+	m.Header.Set(jetstream.MsgIDHeader, msg.ID)
+
+	_, err := p.js.PublishMsg(ctx, m)
+	return err
+}
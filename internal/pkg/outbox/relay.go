@@ -0,0 +1,155 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+// Package outbox publishes the messages stored in a store.Outbox. As the messages are written in the same
+// transaction as the changes they announce, and only marked sent once published, a crash can't lose a message
+// nor publish one for a change that was rolled back. It can, however, publish a message twice, when the process
+// stops between publishing and marking it sent: the messages carry their ID as the Nats-Msg-Id header, so that
+// JetStream drops the duplicates.
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+const (
+	// DefaultInterval is how often the relay looks for messages to publish, when Options.Interval is zero
+	DefaultInterval = time.Second
+	// DefaultBatchSize is how many messages the relay reads at once, when Options.BatchSize is zero
+	DefaultBatchSize = 100
+	// DefaultMinBackoff and DefaultMaxBackoff bound the time between the attempts to publish a message, when
+	// the matching Options are zero
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = time.Minute
+)
+
+// Publisher publishes the messages of the outbox
+type Publisher interface {
+	Publish(ctx context.Context, msg *model.OutboxMessage) error
+}
+
+// Options configures a relay
+type Options struct {
+	Interval  time.Duration
+	BatchSize int
+	// MinBackoff is the wait after the first failed attempt to publish a message, doubling after each new
+	// failure up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Relay publishes the pending messages of an outbox in the background, in the order they were added. A round
+// stops at the first message failing to be published, which is retried after a backoff; the newer messages
+// are not held back by it in the later rounds.
+type Relay struct {
+	outbox    store.Outbox
+	publisher Publisher
+	opts      Options
+	now       func() time.Time
+
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+}
+
+// NewRelay returns a relay publishing the messages of outbox, which is started by Start
+func NewRelay(outbox store.Outbox, publisher Publisher, opts Options) *Relay {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	return &Relay{
+		outbox:    outbox,
+		publisher: publisher,
+		opts:      opts,
+		now:       store.Now,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start publishes the pending messages every Options.Interval, until the relay is closed
+func (r *Relay) Start() {
+	r.startOnce.Do(func() { go r.run() })
+}
+
+func (r *Relay) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if _, err := r.Flush(context.Background()); err != nil {
+				slog.Error("failed to relay the outbox", "error", err)
+			}
+		}
+	}
+}
+
+// Flush publishes the messages due now, returning how many were published
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		msgs, err := r.outbox.Pending(ctx, r.now(), r.opts.BatchSize)
+		if err != nil {
+			return sent, err
+		}
+
+		for _, msg := range msgs {
+			if err := r.publisher.Publish(ctx, msg); err != nil {
+				slog.Warn("failed to publish an outbox message", "id", msg.ID, "subject", msg.Subject, "attempts", msg.Attempts+1, "error", err)
+				return sent, r.outbox.MarkFailed(ctx, msg.ID, err, r.now().Add(r.backoff(msg.Attempts)))
+			}
+			if err := r.outbox.MarkSent(ctx, msg.ID); err != nil {
+				return sent, err
+			}
+			sent++
+		}
+
+		if len(msgs) < r.opts.BatchSize {
+			return sent, nil
+		}
+	}
+}
+
+// backoff returns how long to wait before retrying a message that failed after the given previous attempts
+func (r *Relay) backoff(attempts int) time.Duration {
+	backoff := r.opts.MinBackoff
+	for i := 0; i < attempts && backoff < r.opts.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, r.opts.MaxBackoff)
+}
+
+// Close stops the relay, after publishing the messages still pending. It's safe to call more than once, and on
+// relays never started.
+func (r *Relay) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		// a relay not started yet can't be started anymore
+		r.startOnce.Do(func() { close(r.done) })
+		close(r.stop)
+		<-r.done
+		_, err = r.Flush(context.Background())
+	})
+	return err
+}
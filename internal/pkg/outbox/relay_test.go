@@ -0,0 +1,140 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePublisher records the subjects published, failing while err is set
+type fakePublisher struct {
+	mu        sync.Mutex
+	err       error
+	published []string
+}
+
+func (p *fakePublisher) Publish(_ context.Context, msg *model.OutboxMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, msg.Subject)
+	return nil
+}
+
+func (p *fakePublisher) subjects() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.published...)
+}
+
+func (p *fakePublisher) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func TestRelay_Flush(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	ob := memory.NewOutboxStore()
+	pub := &fakePublisher{}
+	for _, subject := range []string{"a", "b", "c"} {
+		require.NoError(t, ob.Add(ctx, &model.OutboxMessage{Subject: subject}))
+	}
+	relay := NewRelay(ob, pub, Options{BatchSize: 2})
+
+	// test
+	sent, err := relay.Flush(ctx)
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, 3, sent)
+	assert.Equal(t, []string{"a", "b", "c"}, pub.subjects())
+
+	pending, err := ob.Pending(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestRelay_Retries(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	ob := memory.NewOutboxStore()
+	pub := &fakePublisher{err: errors.New("nats is down")}
+	require.NoError(t, ob.Add(ctx, &model.OutboxMessage{Subject: "a"}))
+	require.NoError(t, ob.Add(ctx, &model.OutboxMessage{Subject: "b"}))
+
+	now := time.Now()
+	relay := NewRelay(ob, pub, Options{MinBackoff: time.Second, MaxBackoff: 3 * time.Second})
+	relay.now = func() time.Time { return now }
+
+	// test: the first message fails, ending the round
+	sent, err := relay.Flush(ctx)
+
+	// verify
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	pending, err := ob.Pending(ctx, now.Add(time.Second), 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, "nats is down", pending[0].LastError)
+	assert.Equal(t, now.Add(time.Second), pending[0].NextAttemptAt)
+	assert.Zero(t, pending[1].Attempts)
+
+	// test: not retried before the backoff
+	_, err = relay.Flush(ctx)
+	require.NoError(t, err)
+	pending, err = ob.Pending(ctx, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, 1, pending[1].Attempts)
+
+	// test: the backoff doubles, up to the maximum
+	for _, want := range []time.Duration{2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		now = now.Add(time.Hour)
+		_, err = relay.Flush(ctx)
+		require.NoError(t, err)
+		pending, err = ob.Pending(ctx, now.Add(time.Hour), 10)
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(want), pending[0].NextAttemptAt)
+	}
+
+	// test: published once NATS is back
+	pub.fail(nil)
+	now = now.Add(time.Hour)
+	sent, err = relay.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, []string{"a", "b"}, pub.subjects())
+}
+
+func TestRelay_CloseFlushes(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	ob := memory.NewOutboxStore()
+	pub := &fakePublisher{}
+	relay := NewRelay(ob, pub, Options{Interval: time.Hour})
+	relay.Start()
+	require.NoError(t, ob.Add(ctx, &model.OutboxMessage{Subject: "a"}))
+
+	// test
+	err := relay.Close()
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, pub.subjects())
+	assert.NoError(t, relay.Close())
+}
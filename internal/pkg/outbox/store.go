@@ -0,0 +1,139 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// The actions announced by the events
+const (
+	ActionCreated  = "created"
+	ActionUpdated  = "updated"
+	ActionDeleted  = "deleted"
+	ActionRestored = "restored"
+)
+
+// Event is the payload of the messages announcing the changes made to a record
+type Event struct {
+	Resource string `json:"resource"`
+	ID       string `json:"id"`
+	Action   string `json:"action"`
+	// Record is the record after the change
+	Record json.RawMessage `json:"record"`
+}
+
+// backend is the shape shared by all the store interfaces
+type backend[T any] interface {
+	Get(ctx context.Context, id string) (*T, error)
+	Create(ctx context.Context, record *T) (*T, error)
+	Update(ctx context.Context, record *T) (*T, error)
+	Delete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) (*T, error)
+	List(ctx context.Context, opts store.ListOptions) ([]*T, string, error)
+}
+
+// Store adds an event to the outbox for each change made through it to the records of the decorated store, in
+// the same transaction as the change. The events are published on <subject>.<resource>.<action>. It implements
+// the store interface of its records.
+type Store[T any] struct {
+	next     backend[T]
+	outbox   store.Outbox
+	tx       store.Transactor
+	subject  string
+	resource string
+	id       func(*T) string
+}
+
+// NewUserStore returns a store.User announcing the changes made to the users of next. The transactor must run
+// its transactions on the stores of both next and outbox.
+func NewUserStore(next store.User, outbox store.Outbox, tx store.Transactor, subject string) *Store[model.User] {
+	return &Store[model.User]{next: next, outbox: outbox, tx: tx, subject: subject, resource: "user", id: func(u *model.User) string { return u.ID }}
+}
+
+// NewPlanStore returns a store.Plan announcing the changes made to the plans of next
+func NewPlanStore(next store.Plan, outbox store.Outbox, tx store.Transactor, subject string) *Store[model.Plan] {
+	return &Store[model.Plan]{next: next, outbox: outbox, tx: tx, subject: subject, resource: "plan", id: func(p *model.Plan) string { return p.ID }}
+}
+
+// NewSubscriptionStore returns a store.Subscription announcing the changes made to the subscriptions of next
+func NewSubscriptionStore(next store.Subscription, outbox store.Outbox, tx store.Transactor, subject string) *Store[model.Subscription] {
+	return &Store[model.Subscription]{next: next, outbox: outbox, tx: tx, subject: subject, resource: "subscription", id: func(s *model.Subscription) string { return s.ID }}
+}
+
+// NewPaymentStore returns a store.Payment announcing the changes made to the payments of next
+func NewPaymentStore(next store.Payment, outbox store.Outbox, tx store.Transactor, subject string) *Store[model.Payment] {
+	return &Store[model.Payment]{next: next, outbox: outbox, tx: tx, subject: subject, resource: "payment", id: func(p *model.Payment) string { return p.ID }}
+}
+
+func (s *Store[T]) Get(ctx context.Context, id string) (*T, error) {
+	return s.next.Get(ctx, id)
+}
+
+func (s *Store[T]) Create(ctx context.Context, record *T) (*T, error) {
+	return s.change(ctx, ActionCreated, func(ctx context.Context) (*T, error) {
+		return s.next.Create(ctx, record)
+	})
+}
+
+func (s *Store[T]) Update(ctx context.Context, record *T) (*T, error) {
+	return s.change(ctx, ActionUpdated, func(ctx context.Context) (*T, error) {
+		return s.next.Update(ctx, record)
+	})
+}
+
+func (s *Store[T]) Delete(ctx context.Context, id string) error {
+	_, err := s.change(ctx, ActionDeleted, func(ctx context.Context) (*T, error) {
+		if err := s.next.Delete(ctx, id); err != nil {
+			return nil, err
+		}
+		return s.next.Get(store.WithDeleted(ctx), id)
+	})
+	return err
+}
+
+func (s *Store[T]) Restore(ctx context.Context, id string) (*T, error) {
+	return s.change(ctx, ActionRestored, func(ctx context.Context) (*T, error) {
+		return s.next.Restore(ctx, id)
+	})
+}
+
+func (s *Store[T]) List(ctx context.Context, opts store.ListOptions) ([]*T, string, error) {
+	return s.next.List(ctx, opts)
+}
+
+// change runs fn and adds the event announcing its result to the outbox, in one transaction
+func (s *Store[T]) change(ctx context.Context, action string, fn func(context.Context) (*T, error)) (*T, error) {
+	var changed *T
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		changed, err = fn(ctx)
+		if err != nil {
+			return err
+		}
+
+		record, err := json.Marshal(changed)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(&Event{Resource: s.resource, ID: s.id(changed), Action: action, Record: record})
+		if err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, &model.OutboxMessage{
+			Subject: s.subject + "." + s.resource + "." + action,
+			Headers: reqctx.Headers(ctx),
+			Data:    data,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
@@ -0,0 +1,87 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanStore_Conformance(t *testing.T) {
+	storetest.TestPlan(t, func(*testing.T) store.Plan {
+		return NewPlanStore(memory.NewPlanStore(), memory.NewOutboxStore(), memory.NewTransactor(), "events")
+	})
+}
+
+func TestStore_Events(t *testing.T) {
+	// prepare
+	ctx := reqctx.WithRequestID(context.Background(), "req-1")
+	ob := memory.NewOutboxStore()
+	st := NewPlanStore(memory.NewPlanStore(), ob, memory.NewTransactor(), "events")
+
+	// test
+	_, err := st.Create(ctx, &model.Plan{ID: "plan-1", Name: "Basic"})
+	require.NoError(t, err)
+	_, err = st.Update(ctx, &model.Plan{ID: "plan-1", Name: "Premium"})
+	require.NoError(t, err)
+	require.NoError(t, st.Delete(ctx, "plan-1"))
+	_, err = st.Restore(ctx, "plan-1")
+	require.NoError(t, err)
+
+	// verify
+	msgs, err := ob.Pending(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, msgs, 4)
+	for i, action := range []string{ActionCreated, ActionUpdated, ActionDeleted, ActionRestored} {
+		assert.Equal(t, "events.plan."+action, msgs[i].Subject)
+		assert.Equal(t, "req-1", msgs[i].Headers[reqctx.RequestIDHeader])
+
+		var event Event
+		require.NoError(t, json.Unmarshal(msgs[i].Data, &event))
+		assert.Equal(t, "plan", event.Resource)
+		assert.Equal(t, "plan-1", event.ID)
+		assert.Equal(t, action, event.Action)
+	}
+
+	var event Event
+	require.NoError(t, json.Unmarshal(msgs[1].Data, &event))
+	var plan model.Plan
+	require.NoError(t, json.Unmarshal(event.Record, &plan))
+	assert.Equal(t, "Premium", plan.Name)
+}
+
+func TestStore_RolledBackChangesAreNotAnnounced(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	ob, tx := memory.NewOutboxStore(), memory.NewTransactor()
+	st := NewPlanStore(memory.NewPlanStore(), ob, tx, "events")
+	errBoom := errors.New("boom")
+
+	// test
+	err := tx.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := st.Create(ctx, &model.Plan{ID: "plan-1"}); err != nil {
+			return err
+		}
+		return errBoom
+	})
+	_, errMissing := st.Update(ctx, &model.Plan{ID: "plan-2"})
+
+	// verify
+	assert.ErrorIs(t, err, errBoom)
+	assert.ErrorIs(t, errMissing, store.ErrNotFound)
+	msgs, err := ob.Pending(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+}
@@ -0,0 +1,66 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+// Package reqctx carries the details of the request being served through its context, so that they can be
+// recorded by the layers below the handlers.
+package reqctx
+
+import "context"
+
+const (
+	// RequestIDHeader carries the ID of a request, in HTTP requests and responses and in NATS messages
+	RequestIDHeader = "X-Request-ID"
+	// ActorHeader tells who the client making a request claims to be, in HTTP requests and in NATS messages. It
+	// isn't authenticated.
+	ActorHeader = "X-Actor"
+)
+
+type (
+	actorKey     struct{}
+	requestIDKey struct{}
+)
+
+// WithActor returns a context for a request made by actor
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns who made the request, or an empty string when unknown
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// WithRequestID returns a context for the request with the given ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the request, or an empty string when unknown
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Headers returns the details of the request as headers, to be sent along with the messages it publishes
+func Headers(ctx context.Context) map[string]string {
+	headers := map[string]string{}
+	if id := RequestID(ctx); id != "" {
+		headers[RequestIDHeader] = id
+	}
+	if actor := Actor(ctx); actor != "" {
+		headers[ActorHeader] = actor
+	}
+	return headers
+}
+
+// FromHeaders returns a context with the details of the request found in headers, read with get
+func FromHeaders(ctx context.Context, get func(name string) string) context.Context {
+	if id := get(RequestIDHeader); id != "" {
+		ctx = WithRequestID(ctx, id)
+	}
+	if actor := get(ActorHeader); actor != "" {
+		ctx = WithActor(ctx, actor)
+	}
+	return ctx
+}
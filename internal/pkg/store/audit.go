@@ -0,0 +1,18 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"context"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+)
+
+// Audit persists the audit trail of the changes made to the records of a service
+type Audit interface {
+	// Record adds an entry to the trail, assigning its ID when it has none
+	Record(ctx context.Context, entry *model.AuditEntry) error
+	// History returns the entries recorded for a record, oldest first
+	History(ctx context.Context, resource, id string) ([]*model.AuditEntry, error)
+}
@@ -0,0 +1,197 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+// Package audit has decorators recording the changes made to the records of the stores in a store.Audit. Each
+// change is recorded in the same transaction as the change itself, along with the actor and request ID found in
+// the context, as set by the reqctx package.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// backend is the shape shared by all the store interfaces
+type backend[T any] interface {
+	Get(ctx context.Context, id string) (*T, error)
+	Create(ctx context.Context, record *T) (*T, error)
+	Update(ctx context.Context, record *T) (*T, error)
+	Delete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) (*T, error)
+	List(ctx context.Context, opts store.ListOptions) ([]*T, string, error)
+}
+
+// Store records the changes made through it to the records of the decorated store. It implements the store
+// interface of its records.
+type Store[T any] struct {
+	next     backend[T]
+	audit    store.Audit
+	tx       store.Transactor
+	resource string
+	id       func(*T) string
+}
+
+// NewUserStore returns a store.User recording the changes made to the users of next. The transactor must run
+// its transactions on the stores of both next and audit.
+func NewUserStore(next store.User, audit store.Audit, tx store.Transactor) *Store[model.User] {
+	return &Store[model.User]{next: next, audit: audit, tx: tx, resource: "user", id: func(u *model.User) string { return u.ID }}
+}
+
+// NewPlanStore returns a store.Plan recording the changes made to the plans of next
+func NewPlanStore(next store.Plan, audit store.Audit, tx store.Transactor) *Store[model.Plan] {
+	return &Store[model.Plan]{next: next, audit: audit, tx: tx, resource: "plan", id: func(p *model.Plan) string { return p.ID }}
+}
+
+// NewSubscriptionStore returns a store.Subscription recording the changes made to the subscriptions of next
+func NewSubscriptionStore(next store.Subscription, audit store.Audit, tx store.Transactor) *Store[model.Subscription] {
+	return &Store[model.Subscription]{next: next, audit: audit, tx: tx, resource: "subscription", id: func(s *model.Subscription) string { return s.ID }}
+}
+
+// NewPaymentStore returns a store.Payment recording the changes made to the payments of next
+func NewPaymentStore(next store.Payment, audit store.Audit, tx store.Transactor) *Store[model.Payment] {
+	return &Store[model.Payment]{next: next, audit: audit, tx: tx, resource: "payment", id: func(p *model.Payment) string { return p.ID }}
+}
+
+func (s *Store[T]) Get(ctx context.Context, id string) (*T, error) {
+	return s.next.Get(ctx, id)
+}
+
+func (s *Store[T]) Create(ctx context.Context, record *T) (*T, error) {
+	var created *T
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		created, err = s.next.Create(ctx, record)
+		if err != nil {
+			return err
+		}
+		return s.record(ctx, model.AuditCreate, nil, created)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (s *Store[T]) Update(ctx context.Context, record *T) (*T, error) {
+	var updated *T
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		before, err := s.next.Get(ctx, s.id(record))
+		if err != nil {
+			return err
+		}
+		updated, err = s.next.Update(ctx, record)
+		if err != nil {
+			return err
+		}
+		return s.record(ctx, model.AuditUpdate, before, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *Store[T]) Delete(ctx context.Context, id string) error {
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		before, err := s.next.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.next.Delete(ctx, id); err != nil {
+			return err
+		}
+		after, err := s.next.Get(store.WithDeleted(ctx), id)
+		if err != nil {
+			return err
+		}
+		return s.record(ctx, model.AuditDelete, before, after)
+	})
+}
+
+func (s *Store[T]) Restore(ctx context.Context, id string) (*T, error) {
+	var restored *T
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		before, err := s.next.Get(store.WithDeleted(ctx), id)
+		if err != nil {
+			return err
+		}
+		restored, err = s.next.Restore(ctx, id)
+		if err != nil {
+			return err
+		}
+		return s.record(ctx, model.AuditRestore, before, restored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+func (s *Store[T]) List(ctx context.Context, opts store.ListOptions) ([]*T, string, error) {
+	return s.next.List(ctx, opts)
+}
+
+// record adds an entry for the change from before to after to the audit trail, unless nothing changed
+func (s *Store[T]) record(ctx context.Context, action string, before, after *T) error {
+	changes, err := diff(before, after)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return s.audit.Record(ctx, &model.AuditEntry{
+		Resource:   s.resource,
+		ResourceID: s.id(after),
+		Action:     action,
+		Actor:      reqctx.Actor(ctx),
+		RequestID:  reqctx.RequestID(ctx),
+		Changes:    changes,
+	})
+}
+
+// diff returns the fields that differ between the JSON encodings of before and after, sorted by name. A nil
+// before is taken as a record without fields.
+func diff(before, after any) ([]model.FieldChange, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []model.FieldChange
+	for field, value := range a {
+		if !bytes.Equal(b[field], value) {
+			changes = append(changes, model.FieldChange{Field: field, Before: b[field], After: value})
+		}
+	}
+	for field, value := range b {
+		if _, ok := a[field]; !ok {
+			changes = append(changes, model.FieldChange{Field: field, Before: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func fields(record any) (map[string]json.RawMessage, error) {
+	ret := map[string]json.RawMessage{}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,122 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/reqctx"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanStore_Conformance(t *testing.T) {
+	storetest.TestPlan(t, func(*testing.T) store.Plan {
+		return NewPlanStore(memory.NewPlanStore(), memory.NewAuditStore(), memory.NewTransactor())
+	})
+}
+
+func TestTransactor_Conformance(t *testing.T) {
+	storetest.TestTransactor(t, func(*testing.T) storetest.Stores {
+		audit, tx := memory.NewAuditStore(), memory.NewTransactor()
+		return storetest.Stores{
+			Tx:    tx,
+			Users: NewUserStore(memory.NewUserStore(), audit, tx),
+			Plans: NewPlanStore(memory.NewPlanStore(), audit, tx),
+		}
+	})
+}
+
+// changes returns the changes of an entry by field, as JSON
+func changes(entry *model.AuditEntry) map[string][2]string {
+	ret := map[string][2]string{}
+	for _, c := range entry.Changes {
+		ret[c.Field] = [2]string{string(c.Before), string(c.After)}
+	}
+	return ret
+}
+
+func TestStore_History(t *testing.T) {
+	// prepare
+	ctx := reqctx.WithRequestID(reqctx.WithActor(context.Background(), "john"), "req-1")
+	audit := memory.NewAuditStore()
+	st := NewPlanStore(memory.NewPlanStore(), audit, memory.NewTransactor())
+
+	// test
+	_, err := st.Create(ctx, &model.Plan{ID: "plan-1", Name: "Basic", Price: 10})
+	require.NoError(t, err)
+	_, err = st.Update(ctx, &model.Plan{ID: "plan-1", Name: "Basic", Price: 20})
+	require.NoError(t, err)
+	require.NoError(t, st.Delete(ctx, "plan-1"))
+	_, err = st.Restore(ctx, "plan-1")
+	require.NoError(t, err)
+	_, err = st.Restore(ctx, "plan-1")
+	require.NoError(t, err)
+
+	// verify
+	history, err := audit.History(ctx, "plan", "plan-1")
+	require.NoError(t, err)
+	require.Len(t, history, 4, "restoring a record not deleted changes nothing, and isn't recorded")
+	for i, action := range []string{model.AuditCreate, model.AuditUpdate, model.AuditDelete, model.AuditRestore} {
+		assert.Equal(t, action, history[i].Action)
+		assert.Equal(t, "plan", history[i].Resource)
+		assert.Equal(t, "plan-1", history[i].ResourceID)
+		assert.Equal(t, "john", history[i].Actor)
+		assert.Equal(t, "req-1", history[i].RequestID)
+	}
+
+	assert.Equal(t, [2]string{"", `"Basic"`}, changes(history[0])["name"])
+	assert.Equal(t, [2]string{"10", "20"}, changes(history[1])["price"])
+	assert.NotContains(t, changes(history[1]), "name")
+	assert.Contains(t, changes(history[2]), "deleted_at")
+	assert.Equal(t, "", changes(history[3])["deleted_at"][1])
+}
+
+func TestStore_FailedChangesAreNotRecorded(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	audit := memory.NewAuditStore()
+	st := NewPlanStore(memory.NewPlanStore(), audit, memory.NewTransactor())
+	_, err := st.Create(ctx, &model.Plan{ID: "plan-1"})
+	require.NoError(t, err)
+
+	// test
+	_, errStale := st.Update(ctx, &model.Plan{ID: "plan-1", Version: 42})
+	_, errMissing := st.Update(ctx, &model.Plan{ID: "plan-2"})
+
+	// verify
+	assert.ErrorIs(t, errStale, store.ErrVersionMismatch)
+	assert.ErrorIs(t, errMissing, store.ErrNotFound)
+	history, err := audit.History(ctx, "plan", "plan-1")
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+}
+
+func TestStore_RolledBackChangesAreNotRecorded(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	audit, tx := memory.NewAuditStore(), memory.NewTransactor()
+	st := NewPlanStore(memory.NewPlanStore(), audit, tx)
+	errBoom := errors.New("boom")
+
+	// test
+	err := tx.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := st.Create(ctx, &model.Plan{ID: "plan-1"}); err != nil {
+			return err
+		}
+		return errBoom
+	})
+
+	// verify
+	assert.ErrorIs(t, err, errBoom)
+	history, err := audit.History(ctx, "plan", "plan-1")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
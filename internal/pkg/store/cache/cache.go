@@ -0,0 +1,141 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+// Package cache has read-through caching decorators for the stores. Get is served from the cache while the
+// record is fresh, and Update, Delete and Restore drop the record from the cache once their transaction, if any,
+// is over. The other operations go straight to the decorated store, as do the ones made in transactions or
+// asking for deleted records. Changes not made through the decorator, including the ones committed by other
+// instances of a service, may be seen only once the cached records expire.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSize is the number of records kept by a cache when Options.Size is zero
+const DefaultSize = 1000
+
+// Options configures a cache
+type Options struct {
+	// TTL is how long a record is served from the cache after being read from the store
+	TTL time.Duration
+
+	// Size is the maximum number of records kept in the cache. When full, the least recently used record
+	// makes room for the new one.
+	Size int
+}
+
+// Stats are the counters of a cache
+type Stats struct {
+	// Hits is the number of reads served from the cache
+	Hits uint64
+	// Misses is the number of reads served by the store, either because the record wasn't cached or had expired
+	Misses uint64
+	// Evictions is the number of records dropped to keep the cache within its size
+	Evictions uint64
+}
+
+type entry[T any] struct {
+	key     string
+	value   T
+	expires time.Time
+}
+
+// lru is a size-bound cache whose entries expire after a TTL
+type lru[T any] struct {
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+
+	// generation changes on every removal, so that values read from the store before a removal are not added
+	// to the cache after it
+	generation uint64
+
+	hits, misses, evictions atomic.Uint64
+}
+
+func newLRU[T any](opts Options) *lru[T] {
+	size := opts.Size
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &lru[T]{
+		ttl:     opts.TTL,
+		size:    size,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns the value cached for key, along with the generation to be passed to add on misses
+func (c *lru[T]) get(key string) (T, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry[T])
+		if c.now().Before(e.expires) {
+			c.order.MoveToFront(el)
+			c.hits.Add(1)
+			return e.value, c.generation, true
+		}
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+
+	c.misses.Add(1)
+	var zero T
+	return zero, c.generation, false
+}
+
+// add caches value under key, unless anything was removed from the cache since generation
+func (c *lru[T]) add(key string, value T, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	e := &entry[T]{key: key, value: value, expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(e)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry[T]).key)
+		c.evictions.Add(1)
+	}
+}
+
+func (c *lru[T]) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+func (c *lru[T]) stats() Stats {
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
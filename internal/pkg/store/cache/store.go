@@ -0,0 +1,92 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"context"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// backend is the shape shared by all the store interfaces
+type backend[T any] interface {
+	Get(ctx context.Context, id string) (*T, error)
+	Create(ctx context.Context, record *T) (*T, error)
+	Update(ctx context.Context, record *T) (*T, error)
+	Delete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) (*T, error)
+	List(ctx context.Context, opts store.ListOptions) ([]*T, string, error)
+}
+
+// Store caches the records read from the decorated store. It implements the store interface of its records.
+type Store[T any] struct {
+	next  backend[T]
+	cache *lru[T]
+	id    func(*T) string
+}
+
+// NewPlanStore returns a store.Plan caching the plans read from next
+func NewPlanStore(next store.Plan, opts Options) *Store[model.Plan] {
+	return &Store[model.Plan]{next: next, cache: newLRU[model.Plan](opts), id: func(p *model.Plan) string { return p.ID }}
+}
+
+// NewUserStore returns a store.User caching the users read from next
+func NewUserStore(next store.User, opts Options) *Store[model.User] {
+	return &Store[model.User]{next: next, cache: newLRU[model.User](opts), id: func(u *model.User) string { return u.ID }}
+}
+
+// Stats returns the counters of the cache
+func (s *Store[T]) Stats() Stats {
+	return s.cache.stats()
+}
+
+func (s *Store[T]) Get(ctx context.Context, id string) (*T, error) {
+	// deleted records aren't cached, and the records read in a transaction may still be rolled back
+	if store.IncludesDeleted(ctx) || store.InTx(ctx) {
+		return s.next.Get(ctx, id)
+	}
+
+	cached, generation, ok := s.cache.get(id)
+	if ok {
+		return &cached, nil
+	}
+
+	record, err := s.next.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.add(id, *record, generation)
+	return record, nil
+}
+
+func (s *Store[T]) Create(ctx context.Context, record *T) (*T, error) {
+	return s.next.Create(ctx, record)
+}
+
+func (s *Store[T]) Update(ctx context.Context, record *T) (*T, error) {
+	defer s.invalidate(ctx, s.id(record))
+	return s.next.Update(ctx, record)
+}
+
+func (s *Store[T]) Delete(ctx context.Context, id string) error {
+	defer s.invalidate(ctx, id)
+	return s.next.Delete(ctx, id)
+}
+
+func (s *Store[T]) Restore(ctx context.Context, id string) (*T, error) {
+	defer s.invalidate(ctx, id)
+	return s.next.Restore(ctx, id)
+}
+
+// invalidate drops the record from the cache once the transaction of ctx, if any, is over. Dropping it before
+// would let the reads made in the meantime cache the record as it was before the change, or cache a change
+// still to be rolled back.
+func (s *Store[T]) invalidate(ctx context.Context, id string) {
+	store.AfterTx(ctx, func() { s.cache.remove(id) })
+}
+
+func (s *Store[T]) List(ctx context.Context, opts store.ListOptions) ([]*T, string, error) {
+	return s.next.List(ctx, opts)
+}
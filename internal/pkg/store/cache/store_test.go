@@ -0,0 +1,266 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	storegorm "github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/gorm"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanStore_Conformance(t *testing.T) {
+	storetest.TestPlan(t, func(*testing.T) store.Plan {
+		return NewPlanStore(memory.NewPlanStore(), Options{TTL: time.Minute})
+	})
+}
+
+func TestUserStore_Conformance(t *testing.T) {
+	storetest.TestUser(t, func(*testing.T) store.User {
+		return NewUserStore(memory.NewUserStore(), Options{TTL: time.Minute})
+	})
+}
+
+func TestTransactor_Conformance(t *testing.T) {
+	storetest.TestTransactor(t, func(*testing.T) storetest.Stores {
+		return storetest.Stores{
+			Tx:    memory.NewTransactor(),
+			Users: NewUserStore(memory.NewUserStore(), Options{TTL: time.Minute}),
+			Plans: NewPlanStore(memory.NewPlanStore(), Options{TTL: time.Minute}),
+		}
+	})
+}
+
+// newPlanStore returns a cached store with a plan, along with the decorated store
+func newPlanStore(t *testing.T, opts Options) (*Store[model.Plan], store.Plan) {
+	next := memory.NewPlanStore()
+	_, err := next.Create(context.Background(), &model.Plan{ID: "plan-1", Name: "Basic"})
+	require.NoError(t, err)
+	return NewPlanStore(next, opts), next
+}
+
+func TestStore_Get(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	st, next := newPlanStore(t, Options{TTL: time.Minute})
+
+	// test
+	_, err := st.Get(ctx, "plan-1")
+	require.NoError(t, err)
+	_, err = next.Update(ctx, &model.Plan{ID: "plan-1", Name: "changed behind the cache"})
+	require.NoError(t, err)
+	got, err := st.Get(ctx, "plan-1")
+	require.NoError(t, err)
+
+	// verify
+	assert.Equal(t, "Basic", got.Name)
+	assert.Equal(t, Stats{Hits: 1, Misses: 1}, st.Stats())
+}
+
+func TestStore_GetReturnsCopies(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	st, _ := newPlanStore(t, Options{TTL: time.Minute})
+
+	// test
+	got, err := st.Get(ctx, "plan-1")
+	require.NoError(t, err)
+	got.Name = "changed by the caller"
+	got, err = st.Get(ctx, "plan-1")
+	require.NoError(t, err)
+	got.Name = "changed by the caller"
+
+	// verify
+	got, err = st.Get(ctx, "plan-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Basic", got.Name)
+}
+
+func TestStore_Expiry(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	st, next := newPlanStore(t, Options{TTL: time.Minute})
+	now := time.Now()
+	st.cache.now = func() time.Time { return now }
+	_, err := st.Get(ctx, "plan-1")
+	require.NoError(t, err)
+	_, err = next.Update(ctx, &model.Plan{ID: "plan-1", Name: "Premium"})
+	require.NoError(t, err)
+
+	// test
+	now = now.Add(time.Minute)
+	got, err := st.Get(ctx, "plan-1")
+	require.NoError(t, err)
+
+	// verify
+	assert.Equal(t, "Premium", got.Name)
+	assert.Equal(t, Stats{Misses: 2}, st.Stats())
+}
+
+func TestStore_Eviction(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	st, next := newPlanStore(t, Options{TTL: time.Minute, Size: 2})
+	for _, id := range []string{"plan-2", "plan-3"} {
+		_, err := next.Create(ctx, &model.Plan{ID: id})
+		require.NoError(t, err)
+	}
+
+	// test
+	for _, id := range []string{"plan-1", "plan-2", "plan-1", "plan-3", "plan-1", "plan-2"} {
+		_, err := st.Get(ctx, id)
+		require.NoError(t, err)
+	}
+
+	// verify
+	// plan-2 was the least recently used when plan-3 was added, and plan-3 when plan-2 was added again
+	assert.Equal(t, Stats{Hits: 2, Misses: 4, Evictions: 2}, st.Stats())
+}
+
+func TestStore_Invalidation(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name   string
+		change func(st store.Plan) error
+	}{
+		{
+			name: "update",
+			change: func(st store.Plan) error {
+				_, err := st.Update(ctx, &model.Plan{ID: "plan-1", Name: "Premium"})
+				return err
+			},
+		},
+		{
+			name: "delete",
+			change: func(st store.Plan) error {
+				return st.Delete(ctx, "plan-1")
+			},
+		},
+		{
+			name: "restore",
+			change: func(st store.Plan) error {
+				if err := st.Delete(ctx, "plan-1"); err != nil {
+					return err
+				}
+				_, err := st.Restore(ctx, "plan-1")
+				return err
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// prepare
+			st, next := newPlanStore(t, Options{TTL: time.Minute})
+			_, err := st.Get(ctx, "plan-1")
+			require.NoError(t, err)
+
+			// test
+			require.NoError(t, tc.change(st))
+
+			// verify
+			want, wantErr := next.Get(ctx, "plan-1")
+			got, err := st.Get(ctx, "plan-1")
+			assert.Equal(t, wantErr, err)
+			assert.Equal(t, want, got)
+			assert.Zero(t, st.Stats().Hits)
+		})
+	}
+}
+
+func TestStore_InvalidationAfterTx(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	for _, tc := range []struct {
+		name string
+		// newStores returns the decorated store, with a plan, and its transactor
+		newStores func(t *testing.T) (store.Plan, store.Transactor)
+		// rollback rolls back the transaction instead of committing it
+		rollback bool
+		want     string
+	}{
+		{
+			// a Get made by another client during the transaction reads the committed plan
+			name: "isolated transaction",
+			newStores: func(t *testing.T) (store.Plan, store.Transactor) {
+				db, err := storegorm.Open("sqlite", filepath.Join(t.TempDir(), "plans.db"), storegorm.Pool{})
+				require.NoError(t, err)
+				t.Cleanup(func() { _ = storegorm.Close(db) })
+				m, err := storegorm.NewMigrator(db, "plans", storegorm.PlanMigrations)
+				require.NoError(t, err)
+				_, err = m.Up(ctx)
+				require.NoError(t, err)
+				return storegorm.NewPlanStore(db), storegorm.NewTransactor(db)
+			},
+			want: "Premium",
+		},
+		{
+			// a Get made by another client during the transaction reads the change, which is rolled back
+			name: "rolled back transaction",
+			newStores: func(*testing.T) (store.Plan, store.Transactor) {
+				return memory.NewPlanStore(), memory.NewTransactor()
+			},
+			rollback: true,
+			want:     "Basic",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// prepare
+			next, tx := tc.newStores(t)
+			_, err := next.Create(ctx, &model.Plan{ID: "plan-1", Name: "Basic"})
+			require.NoError(t, err)
+			st := NewPlanStore(next, Options{TTL: time.Minute})
+
+			// test
+			err = tx.WithTx(ctx, func(txCtx context.Context) error {
+				if _, err := st.Update(txCtx, &model.Plan{ID: "plan-1", Name: "Premium"}); err != nil {
+					return err
+				}
+				if _, err := st.Get(ctx, "plan-1"); err != nil {
+					return err
+				}
+				if tc.rollback {
+					return errBoom
+				}
+				return nil
+			})
+			if !tc.rollback {
+				require.NoError(t, err)
+			}
+
+			// verify
+			got, err := st.Get(ctx, "plan-1")
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got.Name)
+		})
+	}
+}
+
+func TestStore_Bypass(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	st, _ := newPlanStore(t, Options{TTL: time.Minute})
+	tx := memory.NewTransactor()
+
+	// test
+	_, err := st.Get(store.WithDeleted(ctx), "plan-1")
+	require.NoError(t, err)
+	err = tx.WithTx(ctx, func(ctx context.Context) error {
+		_, err := st.Get(ctx, "plan-1")
+		return err
+	})
+	require.NoError(t, err)
+
+	// verify
+	assert.Equal(t, Stats{}, st.Stats())
+}
@@ -0,0 +1,19 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import "context"
+
+type includeDeletedKey struct{}
+
+// WithDeleted returns a context that makes Get and List return soft-deleted records as well
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeDeletedKey{}, true)
+}
+
+// IncludesDeleted reports whether soft-deleted records should be returned for operations using ctx
+func IncludesDeleted(ctx context.Context) bool {
+	include, _ := ctx.Value(includeDeletedKey{}).(bool)
+	return include
+}
@@ -0,0 +1,19 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+// Package store defines the storage interfaces used by the services, along with the errors that every
+// implementation returns.
+//
+// Stores own the record IDs and timestamps: Create assigns a new ID when the record has none, and sets
+// CreatedAt and UpdatedAt unless they are already set. Update keeps the original CreatedAt and refreshes UpdatedAt.
+//
+// Records are versioned: Create stores a record at version 1 and every successful Update increments it.
+// An Update carrying a non-zero version only succeeds when it matches the stored version, failing with
+// ErrVersionMismatch otherwise, while a zero version updates the record unconditionally.
+//
+// Delete is a soft delete: it sets the DeletedAt timestamp of the record, which from then on is hidden from
+// Get, Update and List, unless the context was created with WithDeleted. Restore clears the timestamp,
+// making the record visible again. The ID of a deleted record can't be reused by Create.
+//
+// Operations on several stores can be made atomic by running them through a Transactor.
+package store
@@ -0,0 +1,64 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict is returned when the operation clashes with an existing record, like creating a record with an ID already in use
+	ErrConflict = errors.New("conflict")
+
+	// ErrInvalid is returned when the record can't be stored as given, like when it has no ID
+	ErrInvalid = errors.New("invalid")
+
+	// ErrVersionMismatch is returned when an update is based on a version of the record that is no longer the current one
+	ErrVersionMismatch = errors.New("version mismatch")
+)
+
+// ConflictError is the ErrConflict returned when a record clashes with an existing one, which is identified by
+// Kind and ID
+type ConflictError struct {
+	Kind string
+	ID   string
+	// Reason tells what clashes, like "already exists"
+	Reason string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %q %s: %s", e.Kind, e.ID, e.Reason, ErrConflict)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// FieldError tells why the value of a field is invalid
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError is the ErrInvalid returned when fields of a record are invalid, telling which and why
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = fmt.Sprintf("%s %s", f.Field, f.Message)
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(msgs, ", "), ErrInvalid)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalid
+}
@@ -0,0 +1,89 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"gorm.io/gorm"
+)
+
+// auditEntry is how a model.AuditEntry is stored, with the changes encoded as JSON
+type auditEntry struct {
+	ID         string `gorm:"primaryKey"`
+	Resource   string
+	ResourceID string
+	Action     string
+	Actor      string
+	RequestID  string
+	Timestamp  time.Time
+	Changes    string
+}
+
+// Audit keeps the audit trail of a service in its own table, created by the migrations of the service
+type Audit struct {
+	db    *gorm.DB
+	table string
+}
+
+// NewAuditStore returns a store.Audit keeping the entries in the given table
+func NewAuditStore(db *gorm.DB, table string) store.Audit {
+	return &Audit{db: db, table: table}
+}
+
+func (a *Audit) Record(ctx context.Context, entry *model.AuditEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+	row := auditEntry{
+		ID:         entry.ID,
+		Resource:   entry.Resource,
+		ResourceID: entry.ResourceID,
+		Action:     entry.Action,
+		Actor:      entry.Actor,
+		RequestID:  entry.RequestID,
+		Timestamp:  entry.Timestamp,
+		Changes:    string(changes),
+	}
+	if row.ID == "" {
+		row.ID = store.NewID()
+	}
+	if row.Timestamp.IsZero() {
+		row.Timestamp = store.Now()
+	}
+
+	res := conn(ctx, a.db).Table(a.table).Create(&row)
+	return translateError(a.db, res.Error, "audit entry", row.ID)
+}
+
+func (a *Audit) History(ctx context.Context, resource, id string) ([]*model.AuditEntry, error) {
+	var rows []auditEntry
+	// IDs are UUIDv7, so they sort in the order the entries were recorded
+	res := conn(ctx, a.db).Table(a.table).Where("resource = ? AND resource_id = ?", resource, id).Order("id").Find(&rows)
+	if res.Error != nil {
+		return nil, translateError(a.db, res.Error, "audit entry", id)
+	}
+
+	ret := make([]*model.AuditEntry, len(rows))
+	for i, row := range rows {
+		ret[i] = &model.AuditEntry{
+			ID:         row.ID,
+			Resource:   row.Resource,
+			ResourceID: row.ResourceID,
+			Action:     row.Action,
+			Actor:      row.Actor,
+			RequestID:  row.RequestID,
+			Timestamp:  row.Timestamp,
+		}
+		if err := json.Unmarshal([]byte(row.Changes), &ret[i].Changes); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,10 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build cgo
+
+package gorm
+
+func init() {
+	cgoEnabled = true
+}
@@ -0,0 +1,32 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"gorm.io/gorm"
+)
+
+// translateError converts the errors returned by gorm and the underlying driver into the errors defined by the store package
+func translateError(db *gorm.DB, err error, kind, id string) error {
+	if err == nil {
+		return nil
+	}
+
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("%s %q: %w", kind, id, store.ErrNotFound)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &store.ConflictError{Kind: kind, ID: id, Reason: "already exists"}
+	}
+
+	return err
+}
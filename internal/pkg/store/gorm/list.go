@@ -0,0 +1,65 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// list runs a keyset-paginated query for the records selected by opts, returning the cursor for the next page.
+// The field names in opts are validated against fields before being used as column names.
+func list[T any](ctx context.Context, db *gorm.DB, opts store.ListOptions, fields store.Fields[T]) ([]*T, string, error) {
+	if err := store.Check(opts, fields); err != nil {
+		return nil, "", err
+	}
+	cursor, err := store.ParseCursor(opts, fields)
+	if err != nil {
+		return nil, "", err
+	}
+
+	q := notDeleted(ctx, conn(ctx, db))
+	for field, value := range opts.Filters {
+		q = q.Where(clause.Eq{Column: clause.Column{Name: field}, Value: value})
+	}
+	if !opts.CreatedAfter.IsZero() {
+		q = q.Where(clause.Gte{Column: clause.Column{Name: "created_at"}, Value: opts.CreatedAfter})
+	}
+	if !opts.CreatedBefore.IsZero() {
+		q = q.Where(clause.Lt{Column: clause.Column{Name: "created_at"}, Value: opts.CreatedBefore})
+	}
+
+	field, desc := opts.Order()
+	column, id := clause.Column{Name: field}, clause.Column{Name: "id"}
+	if cursor != nil {
+		after := func(column clause.Column, value any) clause.Expression {
+			if desc {
+				return clause.Lt{Column: column, Value: value}
+			}
+			return clause.Gt{Column: column, Value: value}
+		}
+		q = q.Where(clause.Or(
+			after(column, cursor.Value),
+			clause.And(clause.Eq{Column: column, Value: cursor.Value}, after(id, cursor.ID)),
+		))
+	}
+
+	limit := opts.Limit()
+	q = q.Order(clause.OrderByColumn{Column: column, Desc: desc}).
+		Order(clause.OrderByColumn{Column: id, Desc: desc}).
+		Limit(limit + 1)
+
+	var ret []*T
+	if err := q.Find(&ret).Error; err != nil {
+		return nil, "", err
+	}
+	if len(ret) <= limit {
+		return ret, "", nil
+	}
+	ret = ret[:limit]
+	return ret, store.NewCursor(opts, fields, ret[limit-1]), nil
+}
@@ -0,0 +1,56 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	puresqlite "github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestList_Pagination(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	db, err := gorm.Open(puresqlite.Open("file::memory:"))
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Payment{}))
+	st := NewPaymentStore(db)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		_, err := st.Create(ctx, &model.Payment{
+			ID:        fmt.Sprintf("payment-%d", i),
+			Status:    []string{"paid", "failed"}[i%2],
+			CreatedAt: start.Add(time.Duration(i%3) * time.Hour),
+		})
+		require.NoError(t, err)
+	}
+
+	// test
+	var ids []string
+	opts := store.ListOptions{PageSize: 2, Sort: "-created_at", Filters: map[string]string{"status": "paid"}}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 5, "pagination doesn't end")
+		payments, next, err := st.List(ctx, opts)
+		require.NoError(t, err)
+		for _, p := range payments {
+			ids = append(ids, p.ID)
+		}
+		if next == "" {
+			break
+		}
+		opts.Cursor = next
+	}
+
+	// verify
+	assert.Equal(t, []string{"payment-8", "payment-2", "payment-4", "payment-6", "payment-0"}, ids)
+}
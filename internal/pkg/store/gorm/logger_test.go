@@ -0,0 +1,43 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen_Logger(t *testing.T) {
+	// prepare
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	ctx := context.Background()
+	db, err := Open("sqlite-memory", "logger", Pool{})
+	require.NoError(t, err)
+	defer Close(db)
+	m, err := NewMigrator(db, "plans", PlanMigrations)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	buf.Reset()
+
+	// test: queries below the level of the service, and records not found, aren't logged
+	_, err = NewPlanStore(db).Get(ctx, "missing")
+	require.ErrorIs(t, err, store.ErrNotFound)
+	assert.Empty(t, buf.String())
+
+	// test: failed queries are logged as errors
+	require.Error(t, db.Exec("SELECT * FROM missing_table").Error)
+	assert.Contains(t, buf.String(), "level=ERROR")
+	assert.Contains(t, buf.String(), "missing_table")
+}
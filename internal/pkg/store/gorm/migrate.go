@@ -0,0 +1,176 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrSchemaBehind is returned by Migrator.Check when there are migrations yet to be applied
+var ErrSchemaBehind = errors.New("database schema is behind, run the migrate up command")
+
+// Migration is a versioned change to the database schema. Down reverts the changes made by Up.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// MigrationStatus tells whether a migration has been applied to the database, and when
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// schemaMigration records an applied migration. Each store has its own set of migrations, so that stores
+// sharing a database don't step on each other's versions.
+type schemaMigration struct {
+	Store     string `gorm:"primaryKey"`
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrator applies and reverts a set of migrations, in the order of their versions
+type Migrator struct {
+	db         *gorm.DB
+	store      string
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for the given set of migrations, which must be sorted by version
+func NewMigrator(db *gorm.DB, store string, migrations []Migration) (*Migrator, error) {
+	for i, m := range migrations {
+		if m.Up == nil || m.Down == nil {
+			return nil, fmt.Errorf("migration %d of %s must have both up and down steps", m.Version, store)
+		}
+		if i > 0 && m.Version <= migrations[i-1].Version {
+			return nil, fmt.Errorf("migrations of %s are not sorted by version: %d comes after %d", store, m.Version, migrations[i-1].Version)
+		}
+	}
+
+	return &Migrator{db: db, store: store, migrations: migrations}, nil
+}
+
+// Up applies all pending migrations, returning the ones applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{
+				Store:     m.store,
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now().UTC(),
+			}).Error
+		})
+		if err != nil {
+			return ret, fmt.Errorf("failed to apply migration %d (%s) of %s: %w", migration.Version, migration.Name, m.store, err)
+		}
+		ret = append(ret, migration)
+	}
+
+	return ret, nil
+}
+
+// Down reverts the last steps applied migrations, returning the ones reverted
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(ret) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{Store: m.store, Version: migration.Version}).Error
+		})
+		if err != nil {
+			return ret, fmt.Errorf("failed to revert migration %d (%s) of %s: %w", migration.Version, migration.Name, m.store, err)
+		}
+		ret = append(ret, migration)
+	}
+
+	return ret, nil
+}
+
+// Status returns all known migrations, along with the time they were applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		ret[i] = MigrationStatus{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			ret[i].AppliedAt = &record.AppliedAt
+		}
+	}
+	return ret, nil
+}
+
+// Check returns ErrSchemaBehind when there are pending migrations
+func (m *Migrator) Check(ctx context.Context) error {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			return fmt.Errorf("%s: migration %d (%s) is pending: %w", m.store, s.Version, s.Name, ErrSchemaBehind)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]schemaMigration, error) {
+	db := m.db.WithContext(ctx)
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create the migrations table: %w", err)
+	}
+
+	var records []schemaMigration
+	if err := db.Where(&schemaMigration{Store: m.store}).Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	ret := make(map[int]schemaMigration, len(records))
+	for _, r := range records {
+		ret[r.Version] = r
+	}
+	return ret, nil
+}
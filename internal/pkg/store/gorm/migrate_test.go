@@ -0,0 +1,271 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	puresqlite "github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMigrator_UpDown(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	db, err := gorm.Open(puresqlite.Open("file::memory:"))
+	require.NoError(t, err)
+	m, err := NewMigrator(db, "payments", PaymentMigrations)
+	require.NoError(t, err)
+
+	// test
+	require.ErrorIs(t, m.Check(ctx), ErrSchemaBehind)
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+
+	// verify
+	assert.Len(t, applied, len(PaymentMigrations))
+	assert.NoError(t, m.Check(ctx))
+	assert.True(t, db.Migrator().HasIndex("payments", "idx_payments_subscription_id"))
+	assert.True(t, db.Migrator().HasTable("payments_audit"))
+	assert.True(t, db.Migrator().HasTable("payments_outbox"))
+
+	_, err = NewPaymentStore(db).Create(ctx, &model.Payment{SubscriptionID: "sub-1"})
+	assert.NoError(t, err)
+
+	// test again: nothing left to apply
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	// test: revert all migrations but the first
+	reverted, err := m.Down(ctx, 5)
+	require.NoError(t, err)
+	require.Len(t, reverted, 5)
+	assert.Equal(t, 6, reverted[0].Version)
+	assert.Equal(t, 2, reverted[4].Version)
+	assert.False(t, db.Migrator().HasTable("payments_outbox"))
+	assert.False(t, db.Migrator().HasTable("payments_audit"))
+	assert.False(t, db.Migrator().HasIndex("payments", "idx_payments_subscription_id"))
+	assert.ErrorIs(t, m.Check(ctx), ErrSchemaBehind)
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, 6)
+	assert.NotNil(t, status[0].AppliedAt)
+	for _, s := range status[1:] {
+		assert.Nil(t, s.AppliedAt)
+	}
+
+	// test: revert everything
+	reverted, err = m.Down(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, reverted, 1)
+	assert.False(t, db.Migrator().HasTable("payments"))
+}
+
+func TestMigrator_StoresAreIndependent(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	db, err := gorm.Open(puresqlite.Open("file::memory:"))
+	require.NoError(t, err)
+	users, err := NewMigrator(db, "users", UserMigrations)
+	require.NoError(t, err)
+	plans, err := NewMigrator(db, "plans", PlanMigrations)
+	require.NoError(t, err)
+
+	// test
+	_, err = users.Up(ctx)
+	require.NoError(t, err)
+
+	// verify
+	assert.NoError(t, users.Check(ctx))
+	assert.ErrorIs(t, plans.Check(ctx), ErrSchemaBehind)
+}
+
+func TestMigrator_AdoptsExistingTables(t *testing.T) {
+	// prepare: a database created before the migrations existed
+	ctx := context.Background()
+	db, err := gorm.Open(puresqlite.Open("file::memory:"))
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.Plan{}))
+	_, err = NewPlanStore(db).Create(ctx, &model.Plan{ID: "plan-1", Name: "Basic"})
+	require.NoError(t, err)
+	m, err := NewMigrator(db, "plans", PlanMigrations)
+	require.NoError(t, err)
+
+	// test
+	_, err = m.Up(ctx)
+
+	// verify
+	require.NoError(t, err)
+	plan, err := NewPlanStore(db).Get(ctx, "plan-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Basic", plan.Name)
+}
+
+// paymentBaseline is the payments table as created by the first versions of the service, before records were
+// soft-deleted
+type paymentBaseline struct {
+	ID             string `gorm:"primaryKey"`
+	SubscriptionID string
+	Amount         float64
+	Status         string
+	Version        int64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      time.Time
+}
+
+func (paymentBaseline) TableName() string { return "payments" }
+
+func TestMigrator_UpgradesBaselineTables(t *testing.T) {
+	// prepare: a payment stored with the zero time as its deletion time
+	ctx := context.Background()
+	db, err := gorm.Open(puresqlite.Open("file::memory:"))
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&paymentBaseline{}))
+	require.NoError(t, db.Create(&paymentBaseline{ID: "payment-1", SubscriptionID: "sub-1", Amount: 10, Version: 1}).Error)
+	m, err := NewMigrator(db, "payments", PaymentMigrations)
+	require.NoError(t, err)
+
+	// test
+	_, err = m.Up(ctx)
+
+	// verify
+	require.NoError(t, err)
+	st := NewPaymentStore(db)
+	payment, err := st.Get(ctx, "payment-1")
+	require.NoError(t, err)
+	assert.Nil(t, payment.DeletedAt)
+	payments, _, err := st.List(ctx, store.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, payments, 1)
+}
+
+// userBaseline is the users table as it was before records were soft-deleted
+type userBaseline struct {
+	ID        string `gorm:"primaryKey"`
+	Name      string
+	Email     string
+	Version   int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt time.Time
+}
+
+func (userBaseline) TableName() string { return "users" }
+
+func TestMigrator_UpgradesBaselineTablesWithClashes(t *testing.T) {
+	// prepare: users sharing an email, stored with the zero time as their deletion time
+	ctx := context.Background()
+	db, err := gorm.Open(puresqlite.Open("file::memory:"))
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&userBaseline{}))
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, user := range []userBaseline{
+		{ID: "user-2", Name: "John", Email: "John@example.com", CreatedAt: created.Add(time.Hour)},
+		{ID: "user-1", Name: "John", Email: "john@example.com", CreatedAt: created},
+		{ID: "user-3", Name: "Mary", Email: "mary@example.com", CreatedAt: created.Add(2 * time.Hour)},
+	} {
+		user.Version = int64(i + 1)
+		require.NoError(t, db.Create(&user).Error)
+	}
+	m, err := NewMigrator(db, "users", UserMigrations)
+	require.NoError(t, err)
+
+	// test
+	_, err = m.Up(ctx)
+
+	// verify
+	require.NoError(t, err)
+	st := NewUserStore(db)
+	users, _, err := st.List(ctx, store.ListOptions{})
+	require.NoError(t, err)
+	var ids []string
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	assert.ElementsMatch(t, []string{"user-1", "user-3"}, ids, "the oldest user with the email is kept")
+	deleted, err := st.Get(store.WithDeleted(ctx), "user-2")
+	require.NoError(t, err)
+	require.NotNil(t, deleted.DeletedAt)
+	assert.True(t, deleted.DeletedAt.After(created))
+}
+
+func TestMigrator_FailedMigrationIsRolledBack(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	db, err := gorm.Open(puresqlite.Open("file::memory:"))
+	require.NoError(t, err)
+	migrations := append(PlanMigrations[:1:1], Migration{
+		Version: 2,
+		Name:    "broken",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Exec("ALTER TABLE plans ADD COLUMN tier TEXT").Error; err != nil {
+				return err
+			}
+			return errors.New("boom")
+		},
+		Down: func(tx *gorm.DB) error { return nil },
+	})
+	m, err := NewMigrator(db, "plans", migrations)
+	require.NoError(t, err)
+
+	// test
+	applied, err := m.Up(ctx)
+
+	// verify
+	assert.Error(t, err)
+	assert.Len(t, applied, 1)
+	assert.False(t, db.Migrator().HasColumn("plans", "tier"))
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, status[1].AppliedAt)
+}
+
+func TestNewMigrator_Invalid(t *testing.T) {
+	noop := func(tx *gorm.DB) error { return nil }
+
+	_, err := NewMigrator(nil, "plans", []Migration{{Version: 1, Up: noop}})
+	assert.Error(t, err)
+
+	_, err = NewMigrator(nil, "plans", []Migration{{Version: 2, Up: noop, Down: noop}, {Version: 1, Up: noop, Down: noop}})
+	assert.Error(t, err)
+}
+
+func TestMigrations_UniqueIndexes(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	db, err := gorm.Open(puresqlite.Open("file::memory:"))
+	require.NoError(t, err)
+	for store, migrations := range map[string][]Migration{"users": UserMigrations, "subscriptions": SubscriptionMigrations} {
+		m, err := NewMigrator(db, store, migrations)
+		require.NoError(t, err)
+		_, err = m.Up(ctx)
+		require.NoError(t, err)
+	}
+	require.NoError(t, db.Create(&model.User{ID: "user-1", Email: "john@example.com"}).Error)
+	require.NoError(t, db.Create(&model.Subscription{ID: "sub-1", UserID: "user-1", PlanID: "plan-1"}).Error)
+	deletedAt := time.Now()
+
+	// test
+	// the records are written directly, as the stores refuse them before they reach the database
+	errEmail := db.Create(&model.User{ID: "user-2", Email: "John@example.com"}).Error
+	errDeletedEmail := db.Create(&model.User{ID: "user-3", Email: "john@example.com", DeletedAt: &deletedAt}).Error
+	errSubscription := db.Create(&model.Subscription{ID: "sub-2", UserID: "user-1", PlanID: "plan-1"}).Error
+	errDeletedSubscription := db.Create(&model.Subscription{ID: "sub-3", UserID: "user-1", PlanID: "plan-1", DeletedAt: &deletedAt}).Error
+
+	// verify
+	assert.Error(t, errEmail)
+	assert.NoError(t, errDeletedEmail)
+	assert.Error(t, errSubscription)
+	assert.NoError(t, errDeletedSubscription)
+}
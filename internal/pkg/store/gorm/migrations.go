@@ -0,0 +1,277 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"gorm.io/gorm"
+)
+
+// The tables are described by the structs below as they were when each migration was written, so that
+// later changes to the models don't change what the existing migrations do.
+
+type userV1 struct {
+	ID        string `gorm:"primaryKey"`
+	Name      string
+	Email     string
+	Version   int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+}
+
+func (userV1) TableName() string { return "users" }
+
+type planV1 struct {
+	ID          string `gorm:"primaryKey"`
+	Name        string
+	Price       int32
+	Description string
+	Version     int32
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   *time.Time
+}
+
+func (planV1) TableName() string { return "plans" }
+
+type subscriptionV1 struct {
+	ID        string `gorm:"primaryKey"`
+	UserID    string
+	PlanID    string
+	Version   int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+}
+
+func (subscriptionV1) TableName() string { return "subscriptions" }
+
+type paymentV1 struct {
+	ID             string `gorm:"primaryKey"`
+	SubscriptionID string
+	Amount         float64
+	Status         string
+	Version        int64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      *time.Time
+}
+
+func (paymentV1) TableName() string { return "payments" }
+
+// outboxMessageV1 is stored in a table of each service, named by the migration
+type outboxMessageV1 struct {
+	ID            string `gorm:"primaryKey"`
+	Subject       string
+	Headers       string
+	Data          []byte
+	CreatedAt     time.Time
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	SentAt        *time.Time
+}
+
+// auditEntryV1 is stored in a table of each service, named by the migration
+type auditEntryV1 struct {
+	ID         string `gorm:"primaryKey"`
+	Resource   string
+	ResourceID string
+	Action     string
+	Actor      string
+	RequestID  string
+	Timestamp  time.Time
+	Changes    string
+}
+
+// UserMigrations are the schema changes of the users store
+var UserMigrations = []Migration{
+	{Version: 1, Name: "create users", Up: createTable(&userV1{}), Down: dropTable(&userV1{})},
+	{Version: 2, Name: "index users", Up: createIndexes("users", "deleted_at", "created_at", "email"), Down: dropIndexes("users", "deleted_at", "created_at", "email")},
+	{
+		Version: 3, Name: "unique user emails",
+		Up:   createUniqueIndex("users", "idx_users_email_unique", "LOWER(email)", "email <> ''"),
+		Down: dropUniqueIndex("idx_users_email_unique"),
+	},
+	{Version: 4, Name: "create users audit", Up: createAuditTable("users_audit"), Down: dropTableNamed("users_audit")},
+	{Version: 5, Name: "create users outbox", Up: createOutboxTable("users_outbox"), Down: dropTableNamed("users_outbox")},
+	{Version: 6, Name: "clear zero deletion times of users", Up: clearZeroDeletedAt("users", "LOWER(live.email) = LOWER(users.email) AND users.email <> ''"), Down: noop},
+	{Version: 7, Name: "delete sent messages of users outbox", Up: deleteSentMessages("users_outbox"), Down: noop},
+}
+
+// PlanMigrations are the schema changes of the plans store
+var PlanMigrations = []Migration{
+	{Version: 1, Name: "create plans", Up: createTable(&planV1{}), Down: dropTable(&planV1{})},
+	{Version: 2, Name: "index plans", Up: createIndexes("plans", "deleted_at", "created_at"), Down: dropIndexes("plans", "deleted_at", "created_at")},
+	{Version: 3, Name: "create plans audit", Up: createAuditTable("plans_audit"), Down: dropTableNamed("plans_audit")},
+	{Version: 4, Name: "create plans outbox", Up: createOutboxTable("plans_outbox"), Down: dropTableNamed("plans_outbox")},
+	{Version: 5, Name: "clear zero deletion times of plans", Up: clearZeroDeletedAt("plans", ""), Down: noop},
+	{Version: 6, Name: "delete sent messages of plans outbox", Up: deleteSentMessages("plans_outbox"), Down: noop},
+}
+
+// SubscriptionMigrations are the schema changes of the subscriptions store
+var SubscriptionMigrations = []Migration{
+	{Version: 1, Name: "create subscriptions", Up: createTable(&subscriptionV1{}), Down: dropTable(&subscriptionV1{})},
+	{Version: 2, Name: "index subscriptions", Up: createIndexes("subscriptions", "deleted_at", "created_at", "user_id", "plan_id"), Down: dropIndexes("subscriptions", "deleted_at", "created_at", "user_id", "plan_id")},
+	{
+		Version: 3, Name: "unique subscriptions per user and plan",
+		Up:   createUniqueIndex("subscriptions", "idx_subscriptions_user_id_plan_id_unique", "user_id, plan_id", "user_id <> '' AND plan_id <> ''"),
+		Down: dropUniqueIndex("idx_subscriptions_user_id_plan_id_unique"),
+	},
+	{Version: 4, Name: "create subscriptions audit", Up: createAuditTable("subscriptions_audit"), Down: dropTableNamed("subscriptions_audit")},
+	{Version: 5, Name: "create subscriptions outbox", Up: createOutboxTable("subscriptions_outbox"), Down: dropTableNamed("subscriptions_outbox")},
+	{Version: 6, Name: "clear zero deletion times of subscriptions", Up: clearZeroDeletedAt("subscriptions", "live.user_id = subscriptions.user_id AND live.plan_id = subscriptions.plan_id AND subscriptions.user_id <> '' AND subscriptions.plan_id <> ''"), Down: noop},
+	{Version: 7, Name: "delete sent messages of subscriptions outbox", Up: deleteSentMessages("subscriptions_outbox"), Down: noop},
+}
+
+// PaymentMigrations are the schema changes of the payments store
+var PaymentMigrations = []Migration{
+	{Version: 1, Name: "create payments", Up: createTable(&paymentV1{}), Down: dropTable(&paymentV1{})},
+	{Version: 2, Name: "index payments", Up: createIndexes("payments", "deleted_at", "created_at", "subscription_id", "status"), Down: dropIndexes("payments", "deleted_at", "created_at", "subscription_id", "status")},
+	{Version: 3, Name: "create payments audit", Up: createAuditTable("payments_audit"), Down: dropTableNamed("payments_audit")},
+	{Version: 4, Name: "create payments outbox", Up: createOutboxTable("payments_outbox"), Down: dropTableNamed("payments_outbox")},
+	{Version: 5, Name: "clear zero deletion times of payments", Up: clearZeroDeletedAt("payments", ""), Down: noop},
+	{Version: 6, Name: "delete sent messages of payments outbox", Up: deleteSentMessages("payments_outbox"), Down: noop},
+}
+
+// createTable creates the table for the given struct. Databases created before the migrations existed
+// already have the table, in which case it is adopted as is.
+func createTable(table any) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if tx.Migrator().HasTable(table) {
+			return nil
+		}
+		return tx.Migrator().CreateTable(table)
+	}
+}
+
+// createAuditTable creates a table for the audit trail of a service, indexed by the changed records
+func createAuditTable(table string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if err := tx.Table(table).Migrator().CreateTable(&auditEntryV1{}); err != nil {
+			return err
+		}
+		return tx.Exec("CREATE INDEX ? ON ? (resource, resource_id)", gorm.Expr(tx.Statement.Quote(indexName(table, "resource_id"))), gorm.Expr(tx.Statement.Quote(table))).Error
+	}
+}
+
+// createOutboxTable creates a table for the outbox of a service, indexed for finding the messages to publish
+func createOutboxTable(table string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if err := tx.Table(table).Migrator().CreateTable(&outboxMessageV1{}); err != nil {
+			return err
+		}
+		return tx.Exec("CREATE INDEX ? ON ? (sent_at, next_attempt_at)", gorm.Expr(tx.Statement.Quote(indexName(table, "pending"))), gorm.Expr(tx.Statement.Quote(table))).Error
+	}
+}
+
+// clearZeroDeletedAt sets deleted_at to NULL where it holds the zero time. Tables created before records were
+// soft-deleted stored the zero time in deleted_at for every record, which would otherwise look deleted.
+//
+// When the table has a partial unique index, clash is the SQL condition telling whether the record in table
+// clashes with another one, named live, under the index. The records are then restored from the oldest, and
+// the ones that would clash with a restored record are marked as deleted now instead.
+func clearZeroDeletedAt(table, clash string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		// no record was deleted before year 2, and comparing avoids the way each driver writes the zero time
+		zero := time.Date(2, 1, 1, 0, 0, 0, 0, time.UTC)
+		if clash == "" || !partialIndexes(tx) {
+			return tx.Table(table).Where("deleted_at < ?", zero).Update("deleted_at", nil).Error
+		}
+
+		var ids []string
+		if err := tx.Table(table).Where("deleted_at < ?", zero).Order("created_at, id").Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		quoted := gorm.Expr(tx.Statement.Quote(table))
+		for _, id := range ids {
+			err := tx.Exec("UPDATE ? SET deleted_at = NULL WHERE id = ? AND NOT EXISTS (SELECT 1 FROM ? AS live WHERE live.deleted_at IS NULL AND ?)",
+				quoted, id, quoted, gorm.Expr(clash)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Table(table).Where("deleted_at < ?", zero).Update("deleted_at", store.Now()).Error
+	}
+}
+
+// deleteSentMessages removes the messages marked as sent from an outbox table, which kept them before sent
+// messages were removed right away
+func deleteSentMessages(table string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Table(table).Where("sent_at IS NOT NULL").Delete(&outboxMessageV1{}).Error
+	}
+}
+
+// noop is the down step of data migrations that have nothing to revert
+func noop(*gorm.DB) error {
+	return nil
+}
+
+func dropTableNamed(table string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(table)
+	}
+}
+
+func dropTable(table any) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(table)
+	}
+}
+
+// createIndexes creates a single-column index named idx_<table>_<column> for each of the columns
+func createIndexes(table string, columns ...string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, column := range columns {
+			err := tx.Exec("CREATE INDEX ? ON ? (?)", gorm.Expr(tx.Statement.Quote(indexName(table, column))), gorm.Expr(tx.Statement.Quote(table)), gorm.Expr(tx.Statement.Quote(column))).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func dropIndexes(table string, columns ...string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, column := range columns {
+			if err := tx.Migrator().DropIndex(table, indexName(table, column)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func indexName(table, column string) string {
+	return "idx_" + table + "_" + column
+}
+
+// createUniqueIndex creates a unique index on the expression, for the records not deleted matching the condition.
+// MySQL has no partial indexes, so there the uniqueness is only checked by the stores.
+func createUniqueIndex(table, name, expression, condition string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if !partialIndexes(tx) {
+			return nil
+		}
+		return tx.Exec("CREATE UNIQUE INDEX ? ON ? (?) WHERE deleted_at IS NULL AND ?", gorm.Expr(tx.Statement.Quote(name)), gorm.Expr(tx.Statement.Quote(table)), gorm.Expr(expression), gorm.Expr(condition)).Error
+	}
+}
+
+func dropUniqueIndex(name string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if !partialIndexes(tx) {
+			return nil
+		}
+		return tx.Exec("DROP INDEX IF EXISTS ?", gorm.Expr(tx.Statement.Quote(name))).Error
+	}
+}
+
+func partialIndexes(tx *gorm.DB) bool {
+	return tx.Dialector.Name() != "mysql"
+}
@@ -0,0 +1,143 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	puresqlite "github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Driver returns the dialector for a DSN, in the format expected by the driver
+type Driver func(dsn string) gorm.Dialector
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]Driver{
+		// SQLite in a file, using a SQLite implementation written in Go, which works in binaries built without cgo
+		"sqlite": puresqlite.Open,
+		// SQLite in a file, using the reference SQLite implementation, which needs binaries built with cgo
+		"sqlite-cgo": sqlite.Open,
+		// SQLite in memory, shared by the connections opened with the same DSN, which names the database
+		"sqlite-memory": func(dsn string) gorm.Dialector {
+			if dsn == "" {
+				return puresqlite.Open("file::memory:?cache=shared")
+			}
+			return puresqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", dsn))
+		},
+		"postgres": postgres.Open,
+		"mysql":    mysql.Open,
+
+		// former names of sqlite and sqlite-cgo, kept for existing configuration files
+		"pure": puresqlite.Open,
+		"cgo":  sqlite.Open,
+	}
+)
+
+// RegisterDriver makes a driver available to Open under the given name, replacing any driver registered before
+// under the same name
+func RegisterDriver(name string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	drivers[name] = driver
+}
+
+// Drivers returns the names of the registered drivers, sorted
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	ret := make([]string, 0, len(drivers))
+	for name := range drivers {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Pool configures the connection pool of a database. Zero values keep the defaults of database/sql.
+type Pool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// Open connects to the database with the named driver, configures its connection pool and checks that it's
+// reachable. The pools of in-memory databases keep an idle connection and have no connection lifetimes, so that
+// the database isn't dropped along with its last connection. The logs of gorm are written to the default slog logger.
+func Open(driver, dsn string, pool Pool) (*gorm.DB, error) {
+	driversMu.RLock()
+	open, ok := drivers[driver]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown database driver %q, expected one of %v", driver, Drivers())
+	}
+
+	db, err := gorm.Open(open(dsn), &gorm.Config{Logger: slogLogger{}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the %s database: %w", driver, err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if driver == "sqlite-memory" {
+		// an in-memory database is gone once its last connection is closed, so the idle connections are kept
+		pool.MaxIdleConns = max(pool.MaxIdleConns, 1)
+		if pool.ConnMaxLifetime > 0 || pool.ConnMaxIdleTime > 0 {
+			slog.Warn("ignoring the connection lifetimes of an in-memory database", "dsn", dsn)
+			pool.ConnMaxLifetime, pool.ConnMaxIdleTime = 0, 0
+		}
+	}
+	if pool.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+
+	if err := Ping(context.Background(), db); err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Ping checks that the database is reachable
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("database is unreachable: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connections to the database
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
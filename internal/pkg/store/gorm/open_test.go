@@ -0,0 +1,97 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	puresqlite "github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestOpen_UnknownDriver(t *testing.T) {
+	_, err := Open("oracle", "", Pool{})
+	assert.ErrorContains(t, err, `unknown database driver "oracle"`)
+}
+
+func TestOpen_Pool(t *testing.T) {
+	// test
+	db, err := Open("sqlite-memory", "pool", Pool{MaxOpenConns: 3, MaxIdleConns: 2, ConnMaxLifetime: time.Minute})
+	require.NoError(t, err)
+	defer Close(db)
+
+	// verify
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	assert.Equal(t, 3, sqlDB.Stats().MaxOpenConnections)
+	assert.NoError(t, Ping(context.Background(), db))
+}
+
+func TestOpen_MemoryOutlivesConnections(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	db, err := Open("sqlite-memory", "lifetime", Pool{ConnMaxLifetime: time.Nanosecond, ConnMaxIdleTime: time.Nanosecond})
+	require.NoError(t, err)
+	defer Close(db)
+	m, err := NewMigrator(db, "plans", PlanMigrations)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	// test
+	_, err = NewPlanStore(db).Create(ctx, &model.Plan{ID: "plan-1", Name: "Basic"})
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	// verify
+	_, err = NewPlanStore(db).Get(ctx, "plan-1")
+	assert.NoError(t, err)
+}
+
+func TestOpen_SharedMemory(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	first, err := Open("sqlite-memory", "shared", Pool{})
+	require.NoError(t, err)
+	defer Close(first)
+	second, err := Open("sqlite-memory", "shared", Pool{})
+	require.NoError(t, err)
+	defer Close(second)
+	other, err := Open("sqlite-memory", "other", Pool{})
+	require.NoError(t, err)
+	defer Close(other)
+
+	// test
+	m, err := NewMigrator(first, "plans", PlanMigrations)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	_, err = NewPlanStore(first).Create(ctx, &model.Plan{ID: "1"})
+	require.NoError(t, err)
+
+	// verify
+	_, err = NewPlanStore(second).Get(ctx, "1")
+	assert.NoError(t, err)
+	assert.False(t, other.Migrator().HasTable("plans"))
+}
+
+func TestRegisterDriver(t *testing.T) {
+	// prepare
+	RegisterDriver("test", func(dsn string) gorm.Dialector {
+		return puresqlite.Open("file::memory:")
+	})
+
+	// test
+	db, err := Open("test", "", Pool{})
+
+	// verify
+	require.NoError(t, err)
+	assert.NoError(t, Close(db))
+	assert.Contains(t, Drivers(), "test")
+}
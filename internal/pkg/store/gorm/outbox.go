@@ -0,0 +1,124 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"gorm.io/gorm"
+)
+
+// outboxMessage is how a model.OutboxMessage is stored, with the headers encoded as JSON
+type outboxMessage struct {
+	ID            string `gorm:"primaryKey"`
+	Subject       string
+	Headers       string
+	Data          []byte
+	CreatedAt     time.Time
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	SentAt        *time.Time
+}
+
+// Outbox keeps the outbox of a service in its own table, created by the migrations of the service
+type Outbox struct {
+	db    *gorm.DB
+	table string
+}
+
+// NewOutboxStore returns a store.Outbox keeping the messages in the given table
+func NewOutboxStore(db *gorm.DB, table string) store.Outbox {
+	return &Outbox{db: db, table: table}
+}
+
+func (o *Outbox) Add(ctx context.Context, msg *model.OutboxMessage) error {
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return err
+	}
+	row := outboxMessage{
+		ID:            msg.ID,
+		Subject:       msg.Subject,
+		Headers:       string(headers),
+		Data:          msg.Data,
+		CreatedAt:     msg.CreatedAt,
+		NextAttemptAt: msg.NextAttemptAt,
+	}
+	if row.ID == "" {
+		row.ID = store.NewID()
+	}
+	if row.CreatedAt.IsZero() {
+		row.CreatedAt = store.Now()
+	}
+	if row.NextAttemptAt.IsZero() {
+		row.NextAttemptAt = row.CreatedAt
+	}
+
+	res := conn(ctx, o.db).Table(o.table).Create(&row)
+	return translateError(o.db, res.Error, "outbox message", row.ID)
+}
+
+func (o *Outbox) Pending(ctx context.Context, now time.Time, limit int) ([]*model.OutboxMessage, error) {
+	var rows []outboxMessage
+	// IDs are UUIDv7, so they sort in the order the messages were added
+	res := conn(ctx, o.db).Table(o.table).Where("sent_at IS NULL AND next_attempt_at <= ?", now).Order("id").Limit(limit).Find(&rows)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	ret := make([]*model.OutboxMessage, len(rows))
+	for i, row := range rows {
+		ret[i] = &model.OutboxMessage{
+			ID:            row.ID,
+			Subject:       row.Subject,
+			Data:          row.Data,
+			CreatedAt:     row.CreatedAt,
+			Attempts:      row.Attempts,
+			NextAttemptAt: row.NextAttemptAt,
+			LastError:     row.LastError,
+			SentAt:        row.SentAt,
+		}
+		if err := json.Unmarshal([]byte(row.Headers), &ret[i].Headers); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// MarkSent removes the message, so that the table only keeps the messages still to be published
+func (o *Outbox) MarkSent(ctx context.Context, id string) error {
+	res := conn(ctx, o.db).Table(o.table).Where("id = ?", id).Delete(&outboxMessage{})
+	if res.Error != nil {
+		return translateError(o.db, res.Error, "outbox message", id)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("outbox message %q: %w", id, store.ErrNotFound)
+	}
+	return nil
+}
+
+func (o *Outbox) MarkFailed(ctx context.Context, id string, cause error, next time.Time) error {
+	return o.update(ctx, id, map[string]any{
+		"attempts":        gorm.Expr("attempts + 1"),
+		"last_error":      cause.Error(),
+		"next_attempt_at": next,
+	})
+}
+
+func (o *Outbox) update(ctx context.Context, id string, values map[string]any) error {
+	res := conn(ctx, o.db).Table(o.table).Where("id = ?", id).Updates(values)
+	if res.Error != nil {
+		return translateError(o.db, res.Error, "outbox message", id)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("outbox message %q: %w", id, store.ErrNotFound)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ package gorm
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
@@ -15,33 +16,110 @@ type Payment struct {
 	db *gorm.DB
 }
 
-func NewPaymentStore(db *gorm.DB) store.Payment {
+func NewPaymentStore(db *gorm.DB) *Payment {
 	return &Payment{db: db}
 }
 
 func (p *Payment) Get(ctx context.Context, id string) (*model.Payment, error) {
 	ret := &model.Payment{}
-	_ = p.db.WithContext(ctx).Model(ret).First(&ret, "id = ?", id)
+	res := notDeleted(ctx, conn(ctx, p.db)).First(ret, "id = ?", id)
+	if res.Error != nil {
+		return nil, translateError(p.db, res.Error, "payment", id)
+	}
 	return ret, nil
 }
 
 func (p *Payment) Create(ctx context.Context, payment *model.Payment) (*model.Payment, error) {
-	res := p.db.WithContext(ctx).Create(&payment)
-	return payment, res.Error
+	created := *payment
+	if created.ID == "" {
+		created.ID = store.NewID()
+	}
+	if created.CreatedAt.IsZero() {
+		created.CreatedAt = store.Now()
+	}
+	if created.UpdatedAt.IsZero() {
+		created.UpdatedAt = created.CreatedAt
+	}
+	created.Version = 1
+	created.DeletedAt = nil
+	res := conn(ctx, p.db).Create(&created)
+	if res.Error != nil {
+		return nil, translateError(p.db, res.Error, "payment", created.ID)
+	}
+	return &created, nil
 }
 
 func (p *Payment) Update(ctx context.Context, payment *model.Payment) (*model.Payment, error) {
-	res := p.db.WithContext(ctx).Save(&payment)
-	return payment, res.Error
+	current, err := p.Get(ctx, payment.ID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Version != 0 && payment.Version != current.Version {
+		return nil, fmt.Errorf("payment %q is at version %d, not %d: %w", payment.ID, current.Version, payment.Version, store.ErrVersionMismatch)
+	}
+
+	updated := *payment
+	updated.Version = current.Version + 1
+	updated.CreatedAt = current.CreatedAt
+	updated.UpdatedAt = store.Now()
+	updated.DeletedAt = current.DeletedAt
+	res := conn(ctx, p.db).Model(&updated).Select("*").Where("version = ?", current.Version).Updates(&updated)
+	if res.Error != nil {
+		return nil, translateError(p.db, res.Error, "payment", payment.ID)
+	}
+	if res.RowsAffected == 0 {
+		return nil, fmt.Errorf("payment %q was changed concurrently: %w", payment.ID, store.ErrVersionMismatch)
+	}
+	return &updated, nil
 }
 
 func (p *Payment) Delete(ctx context.Context, id string) error {
-	_ = p.db.WithContext(ctx).Delete(&model.Payment{}, "id = ?", id)
+	now := store.Now()
+	res := conn(ctx, p.db).Model(&model.Payment{}).Where("id = ? AND deleted_at IS NULL", id).Updates(map[string]any{
+		"version":    gorm.Expr("version + 1"),
+		"updated_at": now,
+		"deleted_at": now,
+	})
+	if res.Error != nil {
+		return translateError(p.db, res.Error, "payment", id)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("payment %q: %w", id, store.ErrNotFound)
+	}
 	return nil
 }
 
-func (p *Payment) List(ctx context.Context) ([]*model.Payment, error) {
-	var ret []*model.Payment
-	_ = p.db.WithContext(ctx).Find(&ret)
-	return ret, nil
+func (p *Payment) Restore(ctx context.Context, id string) (*model.Payment, error) {
+	current, err := p.Get(store.WithDeleted(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	if current.DeletedAt == nil {
+		return current, nil
+	}
+
+	restored := *current
+	restored.Version++
+	restored.UpdatedAt = store.Now()
+	restored.DeletedAt = nil
+	res := conn(ctx, p.db).Model(&restored).Select("*").Where("version = ?", current.Version).Updates(&restored)
+	if res.Error != nil {
+		return nil, translateError(p.db, res.Error, "payment", id)
+	}
+	if res.RowsAffected == 0 {
+		return nil, fmt.Errorf("payment %q was changed concurrently: %w", id, store.ErrVersionMismatch)
+	}
+	return &restored, nil
+}
+
+func (p *Payment) List(ctx context.Context, opts store.ListOptions) ([]*model.Payment, string, error) {
+	return list(ctx, p.db, opts, store.PaymentFields)
+}
+
+func (p *Payment) CountPurgeable(ctx context.Context, rule store.PurgeRule) (int, error) {
+	return countPurgeable(ctx, p.db, rule, store.PaymentFields)
+}
+
+func (p *Payment) Purge(ctx context.Context, rule store.PurgeRule, limit int) (int, error) {
+	return purge(ctx, p.db, rule, store.PaymentFields, limit)
 }
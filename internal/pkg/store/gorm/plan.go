@@ -0,0 +1,125 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"gorm.io/gorm"
+)
+
+type Plan struct {
+	db *gorm.DB
+}
+
+func NewPlanStore(db *gorm.DB) *Plan {
+	return &Plan{db: db}
+}
+
+func (p *Plan) Get(ctx context.Context, id string) (*model.Plan, error) {
+	ret := &model.Plan{}
+	res := notDeleted(ctx, conn(ctx, p.db)).First(ret, "id = ?", id)
+	if res.Error != nil {
+		return nil, translateError(p.db, res.Error, "plan", id)
+	}
+	return ret, nil
+}
+
+func (p *Plan) Create(ctx context.Context, plan *model.Plan) (*model.Plan, error) {
+	created := *plan
+	if created.ID == "" {
+		created.ID = store.NewID()
+	}
+	if created.CreatedAt.IsZero() {
+		created.CreatedAt = store.Now()
+	}
+	if created.UpdatedAt.IsZero() {
+		created.UpdatedAt = created.CreatedAt
+	}
+	created.Version = 1
+	created.DeletedAt = nil
+	res := conn(ctx, p.db).Create(&created)
+	if res.Error != nil {
+		return nil, translateError(p.db, res.Error, "plan", created.ID)
+	}
+	return &created, nil
+}
+
+func (p *Plan) Update(ctx context.Context, plan *model.Plan) (*model.Plan, error) {
+	current, err := p.Get(ctx, plan.ID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Version != 0 && plan.Version != current.Version {
+		return nil, fmt.Errorf("plan %q is at version %d, not %d: %w", plan.ID, current.Version, plan.Version, store.ErrVersionMismatch)
+	}
+
+	updated := *plan
+	updated.Version = current.Version + 1
+	updated.CreatedAt = current.CreatedAt
+	updated.UpdatedAt = store.Now()
+	updated.DeletedAt = current.DeletedAt
+	res := conn(ctx, p.db).Model(&updated).Select("*").Where("version = ?", current.Version).Updates(&updated)
+	if res.Error != nil {
+		return nil, translateError(p.db, res.Error, "plan", plan.ID)
+	}
+	if res.RowsAffected == 0 {
+		return nil, fmt.Errorf("plan %q was changed concurrently: %w", plan.ID, store.ErrVersionMismatch)
+	}
+	return &updated, nil
+}
+
+func (p *Plan) Delete(ctx context.Context, id string) error {
+	now := store.Now()
+	res := conn(ctx, p.db).Model(&model.Plan{}).Where("id = ? AND deleted_at IS NULL", id).Updates(map[string]any{
+		"version":    gorm.Expr("version + 1"),
+		"updated_at": now,
+		"deleted_at": now,
+	})
+	if res.Error != nil {
+		return translateError(p.db, res.Error, "plan", id)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("plan %q: %w", id, store.ErrNotFound)
+	}
+	return nil
+}
+
+func (p *Plan) Restore(ctx context.Context, id string) (*model.Plan, error) {
+	current, err := p.Get(store.WithDeleted(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	if current.DeletedAt == nil {
+		return current, nil
+	}
+
+	restored := *current
+	restored.Version++
+	restored.UpdatedAt = store.Now()
+	restored.DeletedAt = nil
+	res := conn(ctx, p.db).Model(&restored).Select("*").Where("version = ?", current.Version).Updates(&restored)
+	if res.Error != nil {
+		return nil, translateError(p.db, res.Error, "plan", id)
+	}
+	if res.RowsAffected == 0 {
+		return nil, fmt.Errorf("plan %q was changed concurrently: %w", id, store.ErrVersionMismatch)
+	}
+	return &restored, nil
+}
+
+func (p *Plan) List(ctx context.Context, opts store.ListOptions) ([]*model.Plan, string, error) {
+	return list(ctx, p.db, opts, store.PlanFields)
+}
+
+func (p *Plan) CountPurgeable(ctx context.Context, rule store.PurgeRule) (int, error) {
+	return countPurgeable(ctx, p.db, rule, store.PlanFields)
+}
+
+func (p *Plan) Purge(ctx context.Context, rule store.PurgeRule, limit int) (int, error) {
+	return purge(ctx, p.db, rule, store.PlanFields, limit)
+}
@@ -0,0 +1,60 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// purgeable returns a query for the records selected by rule. The field names in rule are validated against
+// fields before being used as column names.
+func purgeable[T any](ctx context.Context, db *gorm.DB, rule store.PurgeRule, fields store.Fields[T]) (*gorm.DB, error) {
+	if err := store.CheckPurgeRule(rule, fields); err != nil {
+		return nil, err
+	}
+
+	q := conn(ctx, db).Model(new(T))
+	for field, value := range rule.Filters {
+		q = q.Where(clause.Eq{Column: clause.Column{Name: field}, Value: value})
+	}
+	if rule.Deleted {
+		return q.Where("deleted_at IS NOT NULL AND deleted_at < ?", rule.Before), nil
+	}
+	return q.Where("updated_at < ?", rule.Before), nil
+}
+
+func countPurgeable[T any](ctx context.Context, db *gorm.DB, rule store.PurgeRule, fields store.Fields[T]) (int, error) {
+	q, err := purgeable(ctx, db, rule, fields)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = q.Count(&n).Error
+	return int(n), err
+}
+
+// purge removes up to limit records selected by rule. The IDs are selected first, as MySQL doesn't support
+// limits in the subqueries of deletes, and the rule is checked again when deleting, in case the records
+// changed in between.
+func purge[T any](ctx context.Context, db *gorm.DB, rule store.PurgeRule, fields store.Fields[T], limit int) (int, error) {
+	q, err := purgeable(ctx, db, rule, fields)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	if err := q.Order("id").Limit(limit).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	q, _ = purgeable(ctx, db, rule, fields)
+	res := q.Where("id IN ?", ids).Delete(new(T))
+	return int(res.RowsAffected), res.Error
+}
@@ -0,0 +1,19 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"gorm.io/gorm"
+)
+
+// notDeleted hides the soft-deleted records from the query, unless ctx asks for them
+func notDeleted(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if store.IncludesDeleted(ctx) {
+		return db
+	}
+	return db.Where("deleted_at IS NULL")
+}
@@ -0,0 +1,167 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/storetest"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// cgoEnabled tells whether the tests were built with cgo, which the sqlite-cgo driver needs
+var cgoEnabled bool
+
+// testDriver is a database the stores are tested with. Each test gets a new database, except for the ones
+// configured through the environment, which are emptied before and after each test.
+type testDriver struct {
+	name string
+	dsn  func(t *testing.T) string
+	cgo  bool
+}
+
+var memoryDBs atomic.Int64
+
+var testDrivers = []testDriver{
+	{name: "sqlite", dsn: tempFile},
+	{name: "sqlite-cgo", dsn: tempFile, cgo: true},
+	{name: "sqlite-memory", dsn: func(*testing.T) string {
+		return fmt.Sprintf("test-%d", memoryDBs.Add(1))
+	}},
+	{name: "postgres", dsn: fromEnv("STORE_TEST_POSTGRES_DSN")},
+	{name: "mysql", dsn: fromEnv("STORE_TEST_MYSQL_DSN")},
+}
+
+func tempFile(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "test.db")
+}
+
+// fromEnv returns the DSN in the given environment variable, skipping the test when it's not set
+func fromEnv(name string) func(t *testing.T) string {
+	return func(t *testing.T) string {
+		dsn := os.Getenv(name)
+		if dsn == "" {
+			t.Skipf("%s is not set", name)
+		}
+		return dsn
+	}
+}
+
+// forEachDriver runs test once for each of the test drivers
+func forEachDriver(t *testing.T, test func(t *testing.T, d testDriver)) {
+	for _, d := range testDrivers {
+		t.Run(d.name, func(t *testing.T) {
+			if d.cgo && !cgoEnabled {
+				t.Skip("the sqlite-cgo driver needs a build with cgo")
+			}
+			test(t, d)
+		})
+	}
+}
+
+// newDB returns an empty database, migrated with the given migrations
+func newDB(t *testing.T, d testDriver, name string, migrations []Migration) *gorm.DB {
+	db, err := Open(d.name, d.dsn(t), Pool{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = Close(db) })
+
+	migrate(t, db, name, migrations)
+	return db
+}
+
+// migrate applies the migrations to db, reverting them and any previous ones when the test ends
+func migrate(t *testing.T, db *gorm.DB, name string, migrations []Migration) {
+	ctx := context.Background()
+	m, err := NewMigrator(db, name, migrations)
+	require.NoError(t, err)
+	reset := func() {
+		_, err := m.Down(ctx, len(migrations))
+		require.NoError(t, err)
+		require.NoError(t, db.Where(&schemaMigration{Store: name}).Delete(&schemaMigration{}).Error)
+	}
+	reset()
+	t.Cleanup(reset)
+
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+}
+
+func TestUserStore_Conformance(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, d testDriver) {
+		storetest.TestUser(t, func(t *testing.T) store.User {
+			return NewUserStore(newDB(t, d, "users", UserMigrations))
+		})
+	})
+}
+
+func TestPlanStore_Conformance(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, d testDriver) {
+		storetest.TestPlan(t, func(t *testing.T) store.Plan {
+			return NewPlanStore(newDB(t, d, "plans", PlanMigrations))
+		})
+	})
+}
+
+func TestSubscriptionStore_Conformance(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, d testDriver) {
+		storetest.TestSubscription(t, func(t *testing.T) store.Subscription {
+			return NewSubscriptionStore(newDB(t, d, "subscriptions", SubscriptionMigrations))
+		})
+	})
+}
+
+func TestPaymentStore_Conformance(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, d testDriver) {
+		storetest.TestPayment(t, func(t *testing.T) store.Payment {
+			return NewPaymentStore(newDB(t, d, "payments", PaymentMigrations))
+		})
+	})
+}
+
+func TestAuditStore_Conformance(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, d testDriver) {
+		storetest.TestAudit(t, func(t *testing.T) store.Audit {
+			return NewAuditStore(newDB(t, d, "plans", PlanMigrations), "plans_audit")
+		})
+	})
+}
+
+func TestOutboxStore_Conformance(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, d testDriver) {
+		storetest.TestOutbox(t, func(t *testing.T) storetest.OutboxStores {
+			db := newDB(t, d, "payments", PaymentMigrations)
+			return storetest.OutboxStores{Tx: NewTransactor(db), Outbox: NewOutboxStore(db, "payments_outbox")}
+		})
+	})
+}
+
+func TestPurge_Conformance(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, d testDriver) {
+		storetest.TestPurge(t, func(t *testing.T) storetest.PurgeStores {
+			db := newDB(t, d, "plans", PlanMigrations)
+			return storetest.PurgeStores{Tx: NewTransactor(db), Plans: NewPlanStore(db)}
+		})
+	})
+}
+
+func TestTransactor_Conformance(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, d testDriver) {
+		storetest.TestTransactor(t, func(t *testing.T) storetest.Stores {
+			db := newDB(t, d, "users", UserMigrations)
+			migrate(t, db, "plans", PlanMigrations)
+			return storetest.Stores{
+				Tx:    NewTransactor(db),
+				Users: NewUserStore(db),
+				Plans: NewPlanStore(db),
+			}
+		})
+	})
+}
@@ -0,0 +1,152 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"gorm.io/gorm"
+)
+
+type Subscription struct {
+	db *gorm.DB
+}
+
+func NewSubscriptionStore(db *gorm.DB) *Subscription {
+	return &Subscription{db: db}
+}
+
+func (s *Subscription) Get(ctx context.Context, id string) (*model.Subscription, error) {
+	ret := &model.Subscription{}
+	res := notDeleted(ctx, conn(ctx, s.db)).First(ret, "id = ?", id)
+	if res.Error != nil {
+		return nil, translateError(s.db, res.Error, "subscription", id)
+	}
+	return ret, nil
+}
+
+func (s *Subscription) Create(ctx context.Context, subscription *model.Subscription) (*model.Subscription, error) {
+	created := *subscription
+	if created.ID == "" {
+		created.ID = store.NewID()
+	}
+	if created.CreatedAt.IsZero() {
+		created.CreatedAt = store.Now()
+	}
+	if created.UpdatedAt.IsZero() {
+		created.UpdatedAt = created.CreatedAt
+	}
+	created.Version = 1
+	created.DeletedAt = nil
+	if err := s.checkSubscribed(ctx, &created); err != nil {
+		return nil, err
+	}
+	res := conn(ctx, s.db).Create(&created)
+	if res.Error != nil {
+		return nil, translateError(s.db, res.Error, "subscription", created.ID)
+	}
+	return &created, nil
+}
+
+func (s *Subscription) Update(ctx context.Context, subscription *model.Subscription) (*model.Subscription, error) {
+	current, err := s.Get(ctx, subscription.ID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Version != 0 && subscription.Version != current.Version {
+		return nil, fmt.Errorf("subscription %q is at version %d, not %d: %w", subscription.ID, current.Version, subscription.Version, store.ErrVersionMismatch)
+	}
+
+	if err := s.checkSubscribed(ctx, subscription); err != nil {
+		return nil, err
+	}
+
+	updated := *subscription
+	updated.Version = current.Version + 1
+	updated.CreatedAt = current.CreatedAt
+	updated.UpdatedAt = store.Now()
+	updated.DeletedAt = current.DeletedAt
+	res := conn(ctx, s.db).Model(&updated).Select("*").Where("version = ?", current.Version).Updates(&updated)
+	if res.Error != nil {
+		return nil, translateError(s.db, res.Error, "subscription", subscription.ID)
+	}
+	if res.RowsAffected == 0 {
+		return nil, fmt.Errorf("subscription %q was changed concurrently: %w", subscription.ID, store.ErrVersionMismatch)
+	}
+	return &updated, nil
+}
+
+func (s *Subscription) Delete(ctx context.Context, id string) error {
+	now := store.Now()
+	res := conn(ctx, s.db).Model(&model.Subscription{}).Where("id = ? AND deleted_at IS NULL", id).Updates(map[string]any{
+		"version":    gorm.Expr("version + 1"),
+		"updated_at": now,
+		"deleted_at": now,
+	})
+	if res.Error != nil {
+		return translateError(s.db, res.Error, "subscription", id)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("subscription %q: %w", id, store.ErrNotFound)
+	}
+	return nil
+}
+
+func (s *Subscription) Restore(ctx context.Context, id string) (*model.Subscription, error) {
+	current, err := s.Get(store.WithDeleted(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	if current.DeletedAt == nil {
+		return current, nil
+	}
+	if err := s.checkSubscribed(ctx, current); err != nil {
+		return nil, err
+	}
+
+	restored := *current
+	restored.Version++
+	restored.UpdatedAt = store.Now()
+	restored.DeletedAt = nil
+	res := conn(ctx, s.db).Model(&restored).Select("*").Where("version = ?", current.Version).Updates(&restored)
+	if res.Error != nil {
+		return nil, translateError(s.db, res.Error, "subscription", id)
+	}
+	if res.RowsAffected == 0 {
+		return nil, fmt.Errorf("subscription %q was changed concurrently: %w", id, store.ErrVersionMismatch)
+	}
+	return &restored, nil
+}
+
+func (s *Subscription) List(ctx context.Context, opts store.ListOptions) ([]*model.Subscription, string, error) {
+	return list(ctx, s.db, opts, store.SubscriptionFields)
+}
+
+// checkSubscribed returns an error if another subscription not deleted is for the same user and plan as
+// subscription
+func (s *Subscription) checkSubscribed(ctx context.Context, subscription *model.Subscription) error {
+	if subscription.UserID == "" || subscription.PlanID == "" {
+		return nil
+	}
+	var other model.Subscription
+	res := conn(ctx, s.db).Where("user_id = ? AND plan_id = ? AND id <> ? AND deleted_at IS NULL", subscription.UserID, subscription.PlanID, subscription.ID).Limit(1).Find(&other)
+	if res.Error != nil {
+		return translateError(s.db, res.Error, "subscription", subscription.ID)
+	}
+	if res.RowsAffected > 0 {
+		return store.AlreadySubscribed(other.ID, other.UserID, other.PlanID)
+	}
+	return nil
+}
+
+func (s *Subscription) CountPurgeable(ctx context.Context, rule store.PurgeRule) (int, error) {
+	return countPurgeable(ctx, s.db, rule, store.SubscriptionFields)
+}
+
+func (s *Subscription) Purge(ctx context.Context, rule store.PurgeRule, limit int) (int, error) {
+	return purge(ctx, s.db, rule, store.SubscriptionFields, limit)
+}
@@ -0,0 +1,48 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"gorm.io/gorm"
+)
+
+// txKey holds the transaction started on db in a context, so that transactions on different databases don't
+// get mixed up
+type txKey struct {
+	db *gorm.DB
+}
+
+type Transactor struct {
+	db *gorm.DB
+}
+
+// NewTransactor returns a store.Transactor for the stores created with db. Nested transactions are backed by
+// savepoints.
+func NewTransactor(db *gorm.DB) store.Transactor {
+	return &Transactor{db: db}
+}
+
+func (t *Transactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	txCtx, end := store.MarkTx(ctx)
+	defer end()
+	return conn(ctx, t.db).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(txCtx, txKey{db: t.db}, tx))
+	})
+}
+
+// Isolated tells that the changes made in the transactions are only seen by other callers once committed
+func (t *Transactor) Isolated() bool {
+	return true
+}
+
+// conn returns the transaction started on db for ctx, or db itself when there is none, bound to ctx
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{db: db}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,151 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package gorm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"gorm.io/gorm"
+)
+
+type User struct {
+	db *gorm.DB
+}
+
+func NewUserStore(db *gorm.DB) *User {
+	return &User{db: db}
+}
+
+func (u *User) Get(ctx context.Context, id string) (*model.User, error) {
+	ret := &model.User{}
+	res := notDeleted(ctx, conn(ctx, u.db)).First(ret, "id = ?", id)
+	if res.Error != nil {
+		return nil, translateError(u.db, res.Error, "user", id)
+	}
+	return ret, nil
+}
+
+func (u *User) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	created := *user
+	if created.ID == "" {
+		created.ID = store.NewID()
+	}
+	if created.CreatedAt.IsZero() {
+		created.CreatedAt = store.Now()
+	}
+	if created.UpdatedAt.IsZero() {
+		created.UpdatedAt = created.CreatedAt
+	}
+	created.Version = 1
+	created.DeletedAt = nil
+	if err := u.checkEmail(ctx, &created); err != nil {
+		return nil, err
+	}
+	res := conn(ctx, u.db).Create(&created)
+	if res.Error != nil {
+		return nil, translateError(u.db, res.Error, "user", created.ID)
+	}
+	return &created, nil
+}
+
+func (u *User) Update(ctx context.Context, user *model.User) (*model.User, error) {
+	current, err := u.Get(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Version != 0 && user.Version != current.Version {
+		return nil, fmt.Errorf("user %q is at version %d, not %d: %w", user.ID, current.Version, user.Version, store.ErrVersionMismatch)
+	}
+
+	if err := u.checkEmail(ctx, user); err != nil {
+		return nil, err
+	}
+
+	updated := *user
+	updated.Version = current.Version + 1
+	updated.CreatedAt = current.CreatedAt
+	updated.UpdatedAt = store.Now()
+	updated.DeletedAt = current.DeletedAt
+	res := conn(ctx, u.db).Model(&updated).Select("*").Where("version = ?", current.Version).Updates(&updated)
+	if res.Error != nil {
+		return nil, translateError(u.db, res.Error, "user", user.ID)
+	}
+	if res.RowsAffected == 0 {
+		return nil, fmt.Errorf("user %q was changed concurrently: %w", user.ID, store.ErrVersionMismatch)
+	}
+	return &updated, nil
+}
+
+func (u *User) Delete(ctx context.Context, id string) error {
+	now := store.Now()
+	res := conn(ctx, u.db).Model(&model.User{}).Where("id = ? AND deleted_at IS NULL", id).Updates(map[string]any{
+		"version":    gorm.Expr("version + 1"),
+		"updated_at": now,
+		"deleted_at": now,
+	})
+	if res.Error != nil {
+		return translateError(u.db, res.Error, "user", id)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("user %q: %w", id, store.ErrNotFound)
+	}
+	return nil
+}
+
+func (u *User) Restore(ctx context.Context, id string) (*model.User, error) {
+	current, err := u.Get(store.WithDeleted(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	if current.DeletedAt == nil {
+		return current, nil
+	}
+	if err := u.checkEmail(ctx, current); err != nil {
+		return nil, err
+	}
+
+	restored := *current
+	restored.Version++
+	restored.UpdatedAt = store.Now()
+	restored.DeletedAt = nil
+	res := conn(ctx, u.db).Model(&restored).Select("*").Where("version = ?", current.Version).Updates(&restored)
+	if res.Error != nil {
+		return nil, translateError(u.db, res.Error, "user", id)
+	}
+	if res.RowsAffected == 0 {
+		return nil, fmt.Errorf("user %q was changed concurrently: %w", id, store.ErrVersionMismatch)
+	}
+	return &restored, nil
+}
+
+func (u *User) List(ctx context.Context, opts store.ListOptions) ([]*model.User, string, error) {
+	return list(ctx, u.db, opts, store.UserFields)
+}
+
+// checkEmail returns an error if another user not deleted has the email of user
+func (u *User) checkEmail(ctx context.Context, user *model.User) error {
+	if user.Email == "" {
+		return nil
+	}
+	var other model.User
+	res := conn(ctx, u.db).Where("LOWER(email) = LOWER(?) AND id <> ? AND deleted_at IS NULL", user.Email, user.ID).Limit(1).Find(&other)
+	if res.Error != nil {
+		return translateError(u.db, res.Error, "user", user.ID)
+	}
+	if res.RowsAffected > 0 {
+		return store.EmailInUse(other.ID, other.Email)
+	}
+	return nil
+}
+
+func (u *User) CountPurgeable(ctx context.Context, rule store.PurgeRule) (int, error) {
+	return countPurgeable(ctx, u.db, rule, store.UserFields)
+}
+
+func (u *User) Purge(ctx context.Context, rule store.PurgeRule, limit int) (int, error) {
+	return purge(ctx, u.db, rule, store.UserFields, limit)
+}
@@ -0,0 +1,20 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NewID returns a new record ID. IDs are UUIDv7, so the ones generated later sort after the earlier ones.
+func NewID() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// Now returns the current time as recorded in the CreatedAt, UpdatedAt and DeletedAt fields
+func Now() time.Time {
+	return time.Now().UTC()
+}
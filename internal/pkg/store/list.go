@@ -0,0 +1,152 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPageSize is the number of records returned by List when ListOptions.PageSize is zero
+	DefaultPageSize = 50
+
+	// MaxPageSize is the highest number of records returned by a single call to List
+	MaxPageSize = 1000
+)
+
+// ListOptions selects and orders the records returned by List. Records are returned in pages, and the
+// cursor returned along with a page is used to get the next one, as long as the other options are kept.
+type ListOptions struct {
+	// PageSize is the maximum number of records to return, up to MaxPageSize
+	PageSize int
+
+	// Cursor is the position where the previous page ended
+	Cursor string
+
+	// Sort is the name of the field to order the records by, with a "-" prefix for the descending order.
+	// Records with the same value are ordered by ID. Defaults to "id".
+	Sort string
+
+	// Filters restricts the records to the ones where the named fields have the given values
+	Filters map[string]string
+
+	// CreatedAfter, when set, restricts the records to the ones created at or after it
+	CreatedAfter time.Time
+
+	// CreatedBefore, when set, restricts the records to the ones created before it
+	CreatedBefore time.Time
+}
+
+// Fields maps the names of the fields that List can sort and filter by to functions returning their values,
+// which are either strings or time.Time. Only string fields can be used as filters.
+type Fields[T any] map[string]func(*T) any
+
+// Limit returns the number of records to return in a page
+func (o ListOptions) Limit() int {
+	switch {
+	case o.PageSize <= 0:
+		return DefaultPageSize
+	case o.PageSize > MaxPageSize:
+		return MaxPageSize
+	default:
+		return o.PageSize
+	}
+}
+
+// Order returns the field to sort the records by, and whether the order is descending
+func (o ListOptions) Order() (string, bool) {
+	if o.Sort == "" {
+		return "id", false
+	}
+	if field, ok := strings.CutPrefix(o.Sort, "-"); ok {
+		return field, true
+	}
+	return o.Sort, false
+}
+
+// Check validates the options against the fields known for the records
+func Check[T any](o ListOptions, fields Fields[T]) error {
+	if o.PageSize < 0 {
+		return fmt.Errorf("negative page size %d: %w", o.PageSize, ErrInvalid)
+	}
+
+	if field, _ := o.Order(); fields[field] == nil {
+		return fmt.Errorf("can't sort by %q: %w", field, ErrInvalid)
+	}
+
+	var zero T
+	for field := range o.Filters {
+		value, ok := fields[field]
+		if !ok {
+			return fmt.Errorf("can't filter by %q: %w", field, ErrInvalid)
+		}
+		if _, ok := value(&zero).(string); !ok {
+			return fmt.Errorf("can't filter by %q: %w", field, ErrInvalid)
+		}
+	}
+
+	return nil
+}
+
+// Cursor is the position of a record in a listing: the value of the field the records are sorted by, and its ID
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value any    `json:"v"`
+	ID    string `json:"id"`
+}
+
+// NewCursor returns the cursor pointing to the given record
+func NewCursor[T any](o ListOptions, fields Fields[T], record *T) string {
+	field, _ := o.Order()
+	c := Cursor{
+		Sort:  o.Sort,
+		Value: fields[field](record),
+		ID:    fields["id"](record).(string),
+	}
+
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes the cursor from the options, returning nil when the options have no cursor. The returned
+// cursor value has the same type as the values of the field the records are sorted by.
+func ParseCursor[T any](o ListOptions, fields Fields[T]) (*Cursor, error) {
+	if o.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(o.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", ErrInvalid)
+	}
+
+	var raw struct {
+		Sort  string `json:"s"`
+		Value string `json:"v"`
+		ID    string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", ErrInvalid)
+	}
+	if raw.Sort != o.Sort {
+		return nil, fmt.Errorf("cursor was created for another sort order: %w", ErrInvalid)
+	}
+
+	c := &Cursor{Sort: raw.Sort, Value: raw.Value, ID: raw.ID}
+
+	var zero T
+	field, _ := o.Order()
+	if _, ok := fields[field](&zero).(time.Time); ok {
+		c.Value, err = time.Parse(time.RFC3339Nano, raw.Value)
+		if err != nil {
+			return nil, fmt.Errorf("malformed cursor: %w", ErrInvalid)
+		}
+	}
+
+	return c, nil
+}
@@ -0,0 +1,84 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// inMemoryAudit is safe for concurrent use. The entries are kept by resource and record ID, in the order they
+// were recorded.
+type inMemoryAudit struct {
+	mu      sync.RWMutex
+	entries map[auditKey][]*model.AuditEntry
+}
+
+type auditKey struct {
+	resource, id string
+}
+
+func NewAuditStore() store.Audit {
+	return &inMemoryAudit{
+		entries: make(map[auditKey][]*model.AuditEntry),
+	}
+}
+
+func (a *inMemoryAudit) Record(ctx context.Context, entry *model.AuditEntry) error {
+	recorded := copyAuditEntry(entry)
+	if recorded.ID == "" {
+		recorded.ID = store.NewID()
+	}
+	if recorded.Timestamp.IsZero() {
+		recorded.Timestamp = store.Now()
+	}
+	key := auditKey{recorded.Resource, recorded.ResourceID}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries[key] = append(a.entries[key], recorded)
+	onRollback(ctx, func() { a.revert(key, recorded.ID) })
+	return nil
+}
+
+func (a *inMemoryAudit) History(_ context.Context, resource, id string) ([]*model.AuditEntry, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	entries := a.entries[auditKey{resource, id}]
+	ret := make([]*model.AuditEntry, len(entries))
+	for i, entry := range entries {
+		ret[i] = copyAuditEntry(entry)
+	}
+	return ret, nil
+}
+
+// revert removes the entry recorded with the given ID
+func (a *inMemoryAudit) revert(key auditKey, id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries[key] = slices.DeleteFunc(a.entries[key], func(entry *model.AuditEntry) bool { return entry.ID == id })
+	if len(a.entries[key]) == 0 {
+		delete(a.entries, key)
+	}
+}
+
+func copyAuditEntry(entry *model.AuditEntry) *model.AuditEntry {
+	c := *entry
+	c.Changes = make([]model.FieldChange, len(entry.Changes))
+	for i, change := range entry.Changes {
+		c.Changes[i] = model.FieldChange{
+			Field:  change.Field,
+			Before: slices.Clone(change.Before),
+			After:  slices.Clone(change.After),
+		}
+	}
+	return &c
+}
@@ -0,0 +1,17 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/storetest"
+)
+
+func TestAuditStore_Conformance(t *testing.T) {
+	storetest.TestAudit(t, func(*testing.T) store.Audit {
+		return NewAuditStore()
+	})
+}
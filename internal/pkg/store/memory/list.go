@@ -0,0 +1,87 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// page selects, orders and paginates the records according to opts, returning the cursor for the next page
+func page[T any](records []*T, opts store.ListOptions, fields store.Fields[T]) ([]*T, string, error) {
+	if err := store.Check(opts, fields); err != nil {
+		return nil, "", err
+	}
+	cursor, err := store.ParseCursor(opts, fields)
+	if err != nil {
+		return nil, "", err
+	}
+
+	field, desc := opts.Order()
+	id, value := fields["id"], fields[field]
+
+	// compare orders a record relative to the given position, according to the sort order
+	compare := func(record *T, v any, recordID string) int {
+		c := compareValues(value(record), v)
+		if c == 0 {
+			c = strings.Compare(id(record).(string), recordID)
+		}
+		if desc {
+			return -c
+		}
+		return c
+	}
+
+	selected := make([]*T, 0, len(records))
+	for _, record := range records {
+		if !matches(record, opts, fields) {
+			continue
+		}
+		if cursor != nil && compare(record, cursor.Value, cursor.ID) <= 0 {
+			continue
+		}
+		selected = append(selected, record)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return compare(selected[i], value(selected[j]), id(selected[j]).(string)) < 0
+	})
+
+	limit := opts.Limit()
+	if len(selected) <= limit {
+		return selected, "", nil
+	}
+	selected = selected[:limit]
+	return selected, store.NewCursor(opts, fields, selected[limit-1]), nil
+}
+
+func matches[T any](record *T, opts store.ListOptions, fields store.Fields[T]) bool {
+	for field, value := range opts.Filters {
+		if fields[field](record) != value {
+			return false
+		}
+	}
+
+	createdAt := fields["created_at"](record).(time.Time)
+	if !opts.CreatedAfter.IsZero() && createdAt.Before(opts.CreatedAfter) {
+		return false
+	}
+	if !opts.CreatedBefore.IsZero() && !createdAt.Before(opts.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
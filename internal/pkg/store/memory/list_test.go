@@ -0,0 +1,104 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestList_Pagination(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	st := NewSubscriptionStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		_, err := st.Create(ctx, &model.Subscription{
+			ID:        fmt.Sprintf("sub-%d", i),
+			UserID:    fmt.Sprintf("user-%d", i%2),
+			CreatedAt: start.Add(time.Duration(9-i) * time.Hour),
+		})
+		require.NoError(t, err)
+	}
+
+	// test
+	var ids []string
+	opts := store.ListOptions{PageSize: 2, Sort: "-created_at", Filters: map[string]string{"user_id": "user-0"}}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 5, "pagination doesn't end")
+		subscriptions, next, err := st.List(ctx, opts)
+		require.NoError(t, err)
+		for _, s := range subscriptions {
+			ids = append(ids, s.ID)
+		}
+		if next == "" {
+			break
+		}
+		opts.Cursor = next
+	}
+
+	// verify
+	assert.Equal(t, []string{"sub-0", "sub-2", "sub-4", "sub-6", "sub-8"}, ids)
+}
+
+func TestList_CreatedRange(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	st := NewPlanStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		_, err := st.Create(ctx, &model.Plan{ID: fmt.Sprintf("plan-%d", i), CreatedAt: start.AddDate(0, 0, i)})
+		require.NoError(t, err)
+	}
+
+	// test
+	plans, next, err := st.List(ctx, store.ListOptions{
+		CreatedAfter:  start.AddDate(0, 0, 1),
+		CreatedBefore: start.AddDate(0, 0, 3),
+	})
+	require.NoError(t, err)
+
+	// verify
+	assert.Empty(t, next)
+	require.Len(t, plans, 2)
+	assert.Equal(t, "plan-1", plans[0].ID)
+	assert.Equal(t, "plan-2", plans[1].ID)
+}
+
+func TestList_InvalidOptions(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	st := NewUserStore()
+	_, err := st.Create(ctx, &model.User{ID: "123"})
+	require.NoError(t, err)
+	_, _ = st.Create(ctx, &model.User{ID: "456"})
+	_, cursor, err := st.List(ctx, store.ListOptions{PageSize: 1})
+	require.NoError(t, err)
+
+	tests := map[string]store.ListOptions{
+		"unknown sort field":     {Sort: "password"},
+		"unknown filter":         {Filters: map[string]string{"password": "secret"}},
+		"filter on a time field": {Filters: map[string]string{"created_at": "2024-01-01"}},
+		"negative page size":     {PageSize: -1},
+		"malformed cursor":       {Cursor: "not a cursor"},
+		"cursor for other sort":  {Cursor: cursor, Sort: "name"},
+	}
+
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			// test
+			_, _, err := st.List(ctx, opts)
+
+			// verify
+			assert.ErrorIs(t, err, store.ErrInvalid)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// inMemoryOutbox is safe for concurrent use. The messages are kept in the order they were added, and the sent
+// ones are removed. Messages added in a transaction are only kept once it's over without being rolled back, as the
+// memory transactions aren't isolated: the relay could otherwise publish a change that is then rolled back.
+type inMemoryOutbox struct {
+	mu       sync.RWMutex
+	messages *list.List // of *model.OutboxMessage
+	byID     map[string]*list.Element
+}
+
+func NewOutboxStore() store.Outbox {
+	return &inMemoryOutbox{messages: list.New(), byID: make(map[string]*list.Element)}
+}
+
+func (o *inMemoryOutbox) Add(ctx context.Context, msg *model.OutboxMessage) error {
+	added := copyOutboxMessage(msg)
+	if added.ID == "" {
+		added.ID = store.NewID()
+	}
+	if added.CreatedAt.IsZero() {
+		added.CreatedAt = store.Now()
+	}
+	if added.NextAttemptAt.IsZero() {
+		added.NextAttemptAt = added.CreatedAt
+	}
+
+	o.mu.RLock()
+	_, exists := o.byID[added.ID]
+	o.mu.RUnlock()
+	if exists {
+		return &store.ConflictError{Kind: "outbox message", ID: added.ID, Reason: "already exists"}
+	}
+
+	var rolledBack atomic.Bool
+	onRollback(ctx, func() { rolledBack.Store(true) })
+	store.AfterTx(ctx, func() {
+		if rolledBack.Load() {
+			return
+		}
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if _, ok := o.byID[added.ID]; !ok {
+			o.byID[added.ID] = o.messages.PushBack(added)
+		}
+	})
+	return nil
+}
+
+func (o *inMemoryOutbox) Pending(_ context.Context, now time.Time, limit int) ([]*model.OutboxMessage, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	var ret []*model.OutboxMessage
+	for el := o.messages.Front(); el != nil && len(ret) < limit; el = el.Next() {
+		if msg := el.Value.(*model.OutboxMessage); !msg.NextAttemptAt.After(now) {
+			ret = append(ret, copyOutboxMessage(msg))
+		}
+	}
+	return ret, nil
+}
+
+func (o *inMemoryOutbox) MarkSent(ctx context.Context, id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	el, ok := o.byID[id]
+	if !ok {
+		return fmt.Errorf("outbox message %q: %w", id, store.ErrNotFound)
+	}
+	o.messages.Remove(el)
+	delete(o.byID, id)
+	onRollback(ctx, func() { o.revert(id, el.Value.(*model.OutboxMessage)) })
+	return nil
+}
+
+func (o *inMemoryOutbox) MarkFailed(ctx context.Context, id string, cause error, next time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	el, ok := o.byID[id]
+	if !ok {
+		return fmt.Errorf("outbox message %q: %w", id, store.ErrNotFound)
+	}
+	current := el.Value.(*model.OutboxMessage)
+	updated := copyOutboxMessage(current)
+	updated.Attempts++
+	updated.LastError = cause.Error()
+	updated.NextAttemptAt = next
+	el.Value = updated
+	onRollback(ctx, func() { o.revert(id, current) })
+	return nil
+}
+
+// revert puts back the message stored under id before a change. Messages removed since are added back in the
+// order of their IDs, which is the order they were added in.
+func (o *inMemoryOutbox) revert(id string, previous *model.OutboxMessage) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if el, ok := o.byID[id]; ok {
+		el.Value = previous
+		return
+	}
+	for el := o.messages.Front(); el != nil; el = el.Next() {
+		if el.Value.(*model.OutboxMessage).ID > id {
+			o.byID[id] = o.messages.InsertBefore(previous, el)
+			return
+		}
+	}
+	o.byID[id] = o.messages.PushBack(previous)
+}
+
+func copyOutboxMessage(msg *model.OutboxMessage) *model.OutboxMessage {
+	c := *msg
+	c.Headers = maps.Clone(msg.Headers)
+	c.Data = slices.Clone(msg.Data)
+	if msg.SentAt != nil {
+		sentAt := *msg.SentAt
+		c.SentAt = &sentAt
+	}
+	return &c
+}
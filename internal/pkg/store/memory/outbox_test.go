@@ -0,0 +1,52 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxStore_Conformance(t *testing.T) {
+	storetest.TestOutbox(t, func(*testing.T) storetest.OutboxStores {
+		return storetest.OutboxStores{Tx: NewTransactor(), Outbox: NewOutboxStore()}
+	})
+}
+
+func TestOutboxStore_PendingOnceCommitted(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	ob := NewOutboxStore()
+	tx := NewTransactor()
+	errBoom := errors.New("boom")
+
+	for _, want := range []error{errBoom, nil} {
+		// test
+		var during []*model.OutboxMessage
+		err := tx.WithTx(ctx, func(txCtx context.Context) error {
+			if err := ob.Add(txCtx, &model.OutboxMessage{ID: "msg-1", Subject: "s"}); err != nil {
+				return err
+			}
+			var err error
+			during, err = ob.Pending(ctx, store.Now(), 10)
+			require.NoError(t, err)
+			return want
+		})
+
+		// verify
+		assert.ErrorIs(t, err, want)
+		assert.Empty(t, during, "the relay doesn't see messages still to be committed")
+	}
+	after, err := ob.Pending(ctx, store.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, after, 1)
+	assert.Equal(t, "msg-1", after[0].ID)
+}
@@ -5,44 +5,216 @@ package memory
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
 )
 
-type inMemoryPlan struct {
-	store map[string]*model.Plan
+// Plan is safe for concurrent use. It keeps its own copies of the plans, so changes made by callers
+// to the values they pass in or get back are not seen by the store.
+type Plan struct {
+	mu    sync.RWMutex
+	store *table[model.Plan]
 }
 
-func NewPlanStore() store.Plan {
-	return &inMemoryPlan{
-		store: make(map[string]*model.Plan),
+func NewPlanStore() *Plan {
+	return &Plan{
+		store: newTable(func(p *model.Plan) string { return p.ID }),
 	}
 }
 
-func (u *inMemoryPlan) Get(_ context.Context, id string) (*model.Plan, error) {
-	return u.store[id], nil
+func (u *Plan) Get(ctx context.Context, id string) (*model.Plan, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	plan, ok := u.store.get(id)
+	if !ok || (plan.DeletedAt != nil && !store.IncludesDeleted(ctx)) {
+		return nil, fmt.Errorf("plan %q: %w", id, store.ErrNotFound)
+	}
+	return copyPlan(plan), nil
 }
 
-func (u *inMemoryPlan) Create(_ context.Context, plan *model.Plan) (*model.Plan, error) {
-	u.store[plan.ID] = plan
-	return plan, nil
+func (u *Plan) Create(ctx context.Context, plan *model.Plan) (*model.Plan, error) {
+	created := copyPlan(plan)
+	if created.ID == "" {
+		created.ID = store.NewID()
+	}
+	if created.CreatedAt.IsZero() {
+		created.CreatedAt = store.Now()
+	}
+	if created.UpdatedAt.IsZero() {
+		created.UpdatedAt = created.CreatedAt
+	}
+	created.Version = 1
+	created.DeletedAt = nil
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, ok := u.store.get(created.ID); ok {
+		return nil, &store.ConflictError{Kind: "plan", ID: created.ID, Reason: "already exists"}
+	}
+	u.store.put(created)
+	onRollback(ctx, func() { u.revert(created.ID, nil) })
+	return copyPlan(created), nil
 }
 
-func (u *inMemoryPlan) Update(_ context.Context, plan *model.Plan) (*model.Plan, error) {
-	u.store[plan.ID] = plan
-	return plan, nil
+func (u *Plan) Update(ctx context.Context, plan *model.Plan) (*model.Plan, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	current, ok := u.store.get(plan.ID)
+	if !ok || current.DeletedAt != nil {
+		return nil, fmt.Errorf("plan %q: %w", plan.ID, store.ErrNotFound)
+	}
+	if plan.Version != 0 && plan.Version != current.Version {
+		return nil, fmt.Errorf("plan %q is at version %d, not %d: %w", plan.ID, current.Version, plan.Version, store.ErrVersionMismatch)
+	}
+
+	updated := copyPlan(plan)
+	updated.Version = current.Version + 1
+	updated.CreatedAt = current.CreatedAt
+	updated.UpdatedAt = store.Now()
+	updated.DeletedAt = nil
+	u.store.put(updated)
+	onRollback(ctx, func() { u.revert(current.ID, current) })
+	return copyPlan(updated), nil
 }
 
-func (u *inMemoryPlan) Delete(_ context.Context, id string) error {
-	delete(u.store, id)
+func (u *Plan) Delete(ctx context.Context, id string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	current, ok := u.store.get(id)
+	if !ok || current.DeletedAt != nil {
+		return fmt.Errorf("plan %q: %w", id, store.ErrNotFound)
+	}
+
+	now := store.Now()
+	deleted := copyPlan(current)
+	deleted.Version++
+	deleted.UpdatedAt = now
+	deleted.DeletedAt = &now
+	u.store.put(deleted)
+	onRollback(ctx, func() { u.revert(id, current) })
 	return nil
 }
 
-func (u *inMemoryPlan) List(_ context.Context) ([]*model.Plan, error) {
-	plans := make([]*model.Plan, 0, len(u.store))
-	for _, plan := range u.store {
+func (u *Plan) Restore(ctx context.Context, id string) (*model.Plan, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	current, ok := u.store.get(id)
+	if !ok {
+		return nil, fmt.Errorf("plan %q: %w", id, store.ErrNotFound)
+	}
+	if current.DeletedAt == nil {
+		return copyPlan(current), nil
+	}
+
+	restored := copyPlan(current)
+	restored.Version++
+	restored.UpdatedAt = store.Now()
+	restored.DeletedAt = nil
+	u.store.put(restored)
+	onRollback(ctx, func() { u.revert(id, current) })
+	return copyPlan(restored), nil
+}
+
+func (u *Plan) List(ctx context.Context, opts store.ListOptions) ([]*model.Plan, string, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	includeDeleted := store.IncludesDeleted(ctx)
+	plans := make([]*model.Plan, 0, u.store.len())
+	for _, plan := range u.store.candidates(opts) {
+		if plan.DeletedAt != nil && !includeDeleted {
+			continue
+		}
 		plans = append(plans, plan)
 	}
-	return plans, nil
+
+	plans, next, err := page(plans, opts, store.PlanFields)
+	if err != nil {
+		return nil, "", err
+	}
+	for i, plan := range plans {
+		plans[i] = copyPlan(plan)
+	}
+	return plans, next, nil
+}
+
+func (u *Plan) WriteSnapshot(w io.Writer) error {
+	u.mu.RLock()
+	plans := make([]*model.Plan, 0, u.store.len())
+	for _, plan := range u.store.all() {
+		plans = append(plans, copyPlan(plan))
+	}
+	u.mu.RUnlock()
+
+	return writeSnapshot(w, plans)
+}
+
+func (u *Plan) ReadSnapshot(r io.Reader) error {
+	plans, err := readSnapshot(r, func(plan *model.Plan) string { return plan.ID })
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.store.reset(plans)
+	return nil
+}
+
+func (u *Plan) CountPurgeable(ctx context.Context, rule store.PurgeRule) (int, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	ids, err := purgeable(u.store.all(), rule, store.PlanFields, func(p *model.Plan) *time.Time { return p.DeletedAt })
+	return len(ids), err
+}
+
+func (u *Plan) Purge(ctx context.Context, rule store.PurgeRule, limit int) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	ids, err := purgeable(u.store.all(), rule, store.PlanFields, func(p *model.Plan) *time.Time { return p.DeletedAt })
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	for _, id := range ids {
+		current, _ := u.store.get(id)
+		u.store.remove(id)
+		onRollback(ctx, func() { u.revert(id, current) })
+	}
+	return len(ids), nil
+}
+
+// revert puts back the plan stored under id before a change, removing it when there was none
+func (u *Plan) revert(id string, previous *model.Plan) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if previous == nil {
+		u.store.remove(id)
+		return
+	}
+	u.store.put(previous)
+}
+
+func copyPlan(plan *model.Plan) *model.Plan {
+	c := *plan
+	if plan.DeletedAt != nil {
+		deletedAt := *plan.DeletedAt
+		c.DeletedAt = &deletedAt
+	}
+	return &c
 }
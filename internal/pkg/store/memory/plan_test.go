@@ -0,0 +1,23 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/storetest"
+)
+
+func TestPlanStore_Conformance(t *testing.T) {
+	storetest.TestPlan(t, func(*testing.T) store.Plan {
+		return NewPlanStore()
+	})
+}
+
+func TestPlanStore_PurgeConformance(t *testing.T) {
+	storetest.TestPurge(t, func(*testing.T) storetest.PurgeStores {
+		return storetest.PurgeStores{Tx: NewTransactor(), Plans: NewPlanStore()}
+	})
+}
@@ -0,0 +1,36 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// purgeable returns the IDs of the records selected by rule, sorted
+func purgeable[T any](records []*T, rule store.PurgeRule, fields store.Fields[T], deletedAt func(*T) *time.Time) ([]string, error) {
+	if err := store.CheckPurgeRule(rule, fields); err != nil {
+		return nil, err
+	}
+
+	opts := store.ListOptions{Filters: rule.Filters}
+	var ids []string
+	for _, record := range records {
+		if !matches(record, opts, fields) {
+			continue
+		}
+		if rule.Deleted {
+			if at := deletedAt(record); at == nil || !at.Before(rule.Before) {
+				continue
+			}
+		} else if !fields["updated_at"](record).(time.Time).Before(rule.Before) {
+			continue
+		}
+		ids = append(ids, fields["id"](record).(string))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
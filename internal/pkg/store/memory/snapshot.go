@@ -0,0 +1,162 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Snapshotter is implemented by all the memory stores. WriteSnapshot writes all records, including the deleted
+// ones, and ReadSnapshot replaces the records of the store with the ones in the snapshot.
+type Snapshotter interface {
+	WriteSnapshot(w io.Writer) error
+	ReadSnapshot(r io.Reader) error
+}
+
+// SaveSnapshot writes the snapshot of s to path. The snapshot is written to a temporary file first, which then
+// replaces the one at path, so that a crash while saving leaves the previous snapshot in place.
+func SaveSnapshot(s Snapshotter, path string) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create the snapshot file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if err := s.WriteSnapshot(f); err != nil {
+		return fmt.Errorf("failed to write the snapshot: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to write the snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write the snapshot: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace the snapshot: %w", err)
+	}
+
+	// makes the rename durable; not all platforms support syncing directories, so failures are ignored
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		_ = dir.Sync()
+		_ = dir.Close()
+	}
+	return nil
+}
+
+// LoadSnapshot reads the snapshot at path into s. A missing snapshot leaves s untouched.
+func LoadSnapshot(s Snapshotter, path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open the snapshot: %w", err)
+	}
+	defer f.Close()
+
+	if err := s.ReadSnapshot(f); err != nil {
+		return fmt.Errorf("failed to read the snapshot %s: %w", path, err)
+	}
+	return nil
+}
+
+// Snapshots saves the snapshot of a store periodically, and once more when closed
+type Snapshots struct {
+	s    Snapshotter
+	path string
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+	err  error
+}
+
+// StartSnapshots loads the snapshot at path into s, and starts saving s to it every interval. With a zero
+// interval, the snapshot is only saved when the returned Snapshots is closed.
+func StartSnapshots(s Snapshotter, path string, interval time.Duration) (*Snapshots, error) {
+	if err := LoadSnapshot(s, path); err != nil {
+		return nil, err
+	}
+
+	sn := &Snapshots{
+		s:    s,
+		path: path,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go sn.run(interval)
+	return sn, nil
+}
+
+func (sn *Snapshots) run(interval time.Duration) {
+	defer close(sn.done)
+	if interval <= 0 {
+		<-sn.stop
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := SaveSnapshot(sn.s, sn.path); err != nil {
+				slog.Error("failed to save the snapshot", "path", sn.path, "error", err)
+			}
+		case <-sn.stop:
+			return
+		}
+	}
+}
+
+// Close stops the periodic saves and saves the final snapshot
+func (sn *Snapshots) Close() error {
+	sn.once.Do(func() {
+		close(sn.stop)
+		<-sn.done
+		sn.err = SaveSnapshot(sn.s, sn.path)
+	})
+	return sn.err
+}
+
+// writeSnapshot writes the records in the order they were added to the store, which is kept when the snapshot is
+// read back
+func writeSnapshot[T any](w io.Writer, records []*T) error {
+	return json.NewEncoder(w).Encode(records)
+}
+
+// readSnapshot returns the records of a snapshot, in the order they were written
+func readSnapshot[T any](r io.Reader, id func(*T) string) ([]*T, error) {
+	var records []*T
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(records))
+	for _, record := range records {
+		key := id(record)
+		if key == "" {
+			return nil, errors.New("record without an id")
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate record %q", key)
+		}
+		seen[key] = true
+	}
+	return records, nil
+}
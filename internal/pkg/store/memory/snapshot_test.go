@@ -0,0 +1,155 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.json")
+	st := NewUserStore()
+	_, err := st.Create(ctx, &model.User{ID: "1", Name: "John"})
+	require.NoError(t, err)
+	_, err = st.Create(ctx, &model.User{ID: "2", Name: "Jane"})
+	require.NoError(t, err)
+	_, err = st.Update(ctx, &model.User{ID: "1", Name: "John Doe"})
+	require.NoError(t, err)
+	require.NoError(t, st.Delete(ctx, "2"))
+
+	// test
+	require.NoError(t, SaveSnapshot(st, path))
+	restored := NewUserStore()
+	require.NoError(t, LoadSnapshot(restored, path))
+
+	// verify
+	user, err := restored.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "John Doe", user.Name)
+	assert.EqualValues(t, 2, user.Version)
+
+	_, err = restored.Get(ctx, "2")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	deleted, err := restored.Get(store.WithDeleted(ctx), "2")
+	require.NoError(t, err)
+	assert.NotNil(t, deleted.DeletedAt)
+}
+
+func TestSnapshot_LoadMissing(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	st := NewPlanStore()
+	_, err := st.Create(ctx, &model.Plan{ID: "1"})
+	require.NoError(t, err)
+
+	// test
+	err = LoadSnapshot(st, filepath.Join(t.TempDir(), "missing.json"))
+
+	// verify
+	require.NoError(t, err)
+	_, err = st.Get(ctx, "1")
+	assert.NoError(t, err)
+}
+
+func TestSnapshot_LoadCorrupt(t *testing.T) {
+	for name, content := range map[string]string{
+		"truncated": `[{"id": "1"`,
+		"no id":     `[{"name": "Basic"}]`,
+		"duplicate": `[{"id": "1"}, {"id": "1"}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			// prepare
+			path := filepath.Join(t.TempDir(), "plans.json")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+			// test
+			err := LoadSnapshot(NewPlanStore(), path)
+
+			// verify
+			assert.Error(t, err)
+		})
+	}
+}
+
+// failingSnapshotter writes part of a snapshot, then fails
+type failingSnapshotter struct {
+	Snapshotter
+}
+
+func (failingSnapshotter) WriteSnapshot(w io.Writer) error {
+	_, _ = w.Write([]byte(`[{"id": "half`))
+	return errors.New("boom")
+}
+
+func TestSnapshot_FailedSaveKeepsPrevious(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plans.json")
+	st := NewPlanStore()
+	_, err := st.Create(ctx, &model.Plan{ID: "1", Name: "Basic"})
+	require.NoError(t, err)
+	require.NoError(t, SaveSnapshot(st, path))
+
+	// test
+	err = SaveSnapshot(failingSnapshotter{}, path)
+
+	// verify
+	assert.Error(t, err)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files were left behind")
+
+	restored := NewPlanStore()
+	require.NoError(t, LoadSnapshot(restored, path))
+	plan, err := restored.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "Basic", plan.Name)
+}
+
+func TestStartSnapshots(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	st := NewSubscriptionStore()
+	sn, err := StartSnapshots(st, path, 10*time.Millisecond)
+	require.NoError(t, err)
+	_, err = st.Create(ctx, &model.Subscription{ID: "1", UserID: "user-1"})
+	require.NoError(t, err)
+
+	// test: periodic saves
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// test: the last save happens on close
+	_, err = st.Create(ctx, &model.Subscription{ID: "2", UserID: "user-2"})
+	require.NoError(t, err)
+	require.NoError(t, sn.Close())
+	require.NoError(t, sn.Close())
+
+	// verify
+	restored := NewSubscriptionStore()
+	sn, err = StartSnapshots(restored, path, 0)
+	require.NoError(t, err)
+	defer sn.Close()
+
+	subscriptions, _, err := restored.List(ctx, store.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, subscriptions, 2)
+}
@@ -5,44 +5,242 @@ package memory
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
 )
 
-type inMemorySubscription struct {
-	store map[string]*model.Subscription
+// Subscription is safe for concurrent use. It keeps its own copies of the subscriptions, so changes made by callers
+// to the values they pass in or get back are not seen by the store.
+type Subscription struct {
+	mu    sync.RWMutex
+	store *table[model.Subscription]
 }
 
-func NewSubscriptionStore() store.Subscription {
-	return &inMemorySubscription{
-		store: make(map[string]*model.Subscription),
+func NewSubscriptionStore() *Subscription {
+	return &Subscription{
+		store: newTable(func(s *model.Subscription) string { return s.ID }).
+			withIndex("user_id", func(s *model.Subscription) string { return s.UserID }, false).
+			withIndex("plan_id", func(s *model.Subscription) string { return s.PlanID }, false),
 	}
 }
 
-func (u *inMemorySubscription) Get(_ context.Context, id string) (*model.Subscription, error) {
-	return u.store[id], nil
+func (u *Subscription) Get(ctx context.Context, id string) (*model.Subscription, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	subscription, ok := u.store.get(id)
+	if !ok || (subscription.DeletedAt != nil && !store.IncludesDeleted(ctx)) {
+		return nil, fmt.Errorf("subscription %q: %w", id, store.ErrNotFound)
+	}
+	return copySubscription(subscription), nil
 }
 
-func (u *inMemorySubscription) Create(_ context.Context, user *model.Subscription) (*model.Subscription, error) {
-	u.store[user.ID] = user
-	return user, nil
+func (u *Subscription) Create(ctx context.Context, subscription *model.Subscription) (*model.Subscription, error) {
+	created := copySubscription(subscription)
+	if created.ID == "" {
+		created.ID = store.NewID()
+	}
+	if created.CreatedAt.IsZero() {
+		created.CreatedAt = store.Now()
+	}
+	if created.UpdatedAt.IsZero() {
+		created.UpdatedAt = created.CreatedAt
+	}
+	created.Version = 1
+	created.DeletedAt = nil
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, ok := u.store.get(created.ID); ok {
+		return nil, &store.ConflictError{Kind: "subscription", ID: created.ID, Reason: "already exists"}
+	}
+	if err := u.checkSubscribed(created); err != nil {
+		return nil, err
+	}
+	u.store.put(created)
+	onRollback(ctx, func() { u.revert(created.ID, nil) })
+	return copySubscription(created), nil
 }
 
-func (u *inMemorySubscription) Update(_ context.Context, user *model.Subscription) (*model.Subscription, error) {
-	u.store[user.ID] = user
-	return user, nil
+func (u *Subscription) Update(ctx context.Context, subscription *model.Subscription) (*model.Subscription, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	current, ok := u.store.get(subscription.ID)
+	if !ok || current.DeletedAt != nil {
+		return nil, fmt.Errorf("subscription %q: %w", subscription.ID, store.ErrNotFound)
+	}
+	if subscription.Version != 0 && subscription.Version != current.Version {
+		return nil, fmt.Errorf("subscription %q is at version %d, not %d: %w", subscription.ID, current.Version, subscription.Version, store.ErrVersionMismatch)
+	}
+
+	if err := u.checkSubscribed(subscription); err != nil {
+		return nil, err
+	}
+
+	updated := copySubscription(subscription)
+	updated.Version = current.Version + 1
+	updated.CreatedAt = current.CreatedAt
+	updated.UpdatedAt = store.Now()
+	updated.DeletedAt = nil
+	u.store.put(updated)
+	onRollback(ctx, func() { u.revert(current.ID, current) })
+	return copySubscription(updated), nil
 }
 
-func (u *inMemorySubscription) Delete(_ context.Context, id string) error {
-	delete(u.store, id)
+func (u *Subscription) Delete(ctx context.Context, id string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	current, ok := u.store.get(id)
+	if !ok || current.DeletedAt != nil {
+		return fmt.Errorf("subscription %q: %w", id, store.ErrNotFound)
+	}
+
+	now := store.Now()
+	deleted := copySubscription(current)
+	deleted.Version++
+	deleted.UpdatedAt = now
+	deleted.DeletedAt = &now
+	u.store.put(deleted)
+	onRollback(ctx, func() { u.revert(id, current) })
 	return nil
 }
 
-func (u *inMemorySubscription) List(_ context.Context) ([]*model.Subscription, error) {
-	users := make([]*model.Subscription, 0, len(u.store))
-	for _, user := range u.store {
-		users = append(users, user)
+func (u *Subscription) Restore(ctx context.Context, id string) (*model.Subscription, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	current, ok := u.store.get(id)
+	if !ok {
+		return nil, fmt.Errorf("subscription %q: %w", id, store.ErrNotFound)
+	}
+	if current.DeletedAt == nil {
+		return copySubscription(current), nil
+	}
+	if err := u.checkSubscribed(current); err != nil {
+		return nil, err
+	}
+
+	restored := copySubscription(current)
+	restored.Version++
+	restored.UpdatedAt = store.Now()
+	restored.DeletedAt = nil
+	u.store.put(restored)
+	onRollback(ctx, func() { u.revert(id, current) })
+	return copySubscription(restored), nil
+}
+
+func (u *Subscription) List(ctx context.Context, opts store.ListOptions) ([]*model.Subscription, string, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	includeDeleted := store.IncludesDeleted(ctx)
+	subscriptions := make([]*model.Subscription, 0, u.store.len())
+	for _, subscription := range u.store.candidates(opts) {
+		if subscription.DeletedAt != nil && !includeDeleted {
+			continue
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	subscriptions, next, err := page(subscriptions, opts, store.SubscriptionFields)
+	if err != nil {
+		return nil, "", err
+	}
+	for i, subscription := range subscriptions {
+		subscriptions[i] = copySubscription(subscription)
+	}
+	return subscriptions, next, nil
+}
+
+func (u *Subscription) WriteSnapshot(w io.Writer) error {
+	u.mu.RLock()
+	subscriptions := make([]*model.Subscription, 0, u.store.len())
+	for _, subscription := range u.store.all() {
+		subscriptions = append(subscriptions, copySubscription(subscription))
+	}
+	u.mu.RUnlock()
+
+	return writeSnapshot(w, subscriptions)
+}
+
+func (u *Subscription) ReadSnapshot(r io.Reader) error {
+	subscriptions, err := readSnapshot(r, func(subscription *model.Subscription) string { return subscription.ID })
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.store.reset(subscriptions)
+	return nil
+}
+
+// checkSubscribed returns an error if another subscription not deleted is for the same user and plan as
+// subscription. It must be called with the lock held.
+func (u *Subscription) checkSubscribed(subscription *model.Subscription) error {
+	if subscription.UserID == "" || subscription.PlanID == "" {
+		return nil
+	}
+	for _, other := range u.store.lookup("user_id", subscription.UserID) {
+		if other.ID != subscription.ID && other.DeletedAt == nil && other.PlanID == subscription.PlanID {
+			return store.AlreadySubscribed(other.ID, other.UserID, other.PlanID)
+		}
+	}
+	return nil
+}
+
+func (u *Subscription) CountPurgeable(ctx context.Context, rule store.PurgeRule) (int, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	ids, err := purgeable(u.store.all(), rule, store.SubscriptionFields, func(s *model.Subscription) *time.Time { return s.DeletedAt })
+	return len(ids), err
+}
+
+func (u *Subscription) Purge(ctx context.Context, rule store.PurgeRule, limit int) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	ids, err := purgeable(u.store.all(), rule, store.SubscriptionFields, func(s *model.Subscription) *time.Time { return s.DeletedAt })
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	for _, id := range ids {
+		current, _ := u.store.get(id)
+		u.store.remove(id)
+		onRollback(ctx, func() { u.revert(id, current) })
+	}
+	return len(ids), nil
+}
+
+// revert puts back the subscription stored under id before a change, removing it when there was none
+func (u *Subscription) revert(id string, previous *model.Subscription) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if previous == nil {
+		u.store.remove(id)
+		return
+	}
+	u.store.put(previous)
+}
+
+func copySubscription(subscription *model.Subscription) *model.Subscription {
+	c := *subscription
+	if subscription.DeletedAt != nil {
+		deletedAt := *subscription.DeletedAt
+		c.DeletedAt = &deletedAt
 	}
-	return users, nil
+	return &c
 }
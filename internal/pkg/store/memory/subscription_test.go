@@ -0,0 +1,92 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionStore_Conformance(t *testing.T) {
+	storetest.TestSubscription(t, func(*testing.T) store.Subscription {
+		return NewSubscriptionStore()
+	})
+}
+
+func TestSubscriptionStore_Indexes(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	st := NewSubscriptionStore()
+	for _, sub := range []*model.Subscription{
+		{ID: "sub-3", UserID: "user-1", PlanID: "plan-1"},
+		{ID: "sub-1", UserID: "user-1", PlanID: "plan-2"},
+		{ID: "sub-2", UserID: "user-2", PlanID: "plan-1"},
+	} {
+		_, err := st.Create(ctx, sub)
+		require.NoError(t, err)
+	}
+	ids := func(opts store.ListOptions) []string {
+		subs, _, err := st.List(ctx, opts)
+		require.NoError(t, err)
+		var ret []string
+		for _, sub := range subs {
+			ret = append(ret, sub.ID)
+		}
+		return ret
+	}
+
+	// test
+	_, err := st.Update(ctx, &model.Subscription{ID: "sub-2", UserID: "user-1", PlanID: "plan-3"})
+	require.NoError(t, err)
+	errTx := NewTransactor().WithTx(ctx, func(ctx context.Context) error {
+		_, err := st.Update(ctx, &model.Subscription{ID: "sub-1", UserID: "user-3", PlanID: "plan-2"})
+		require.NoError(t, err)
+		return errors.New("boom")
+	})
+
+	// verify
+	assert.Error(t, errTx)
+	assert.Equal(t, []string{"sub-1", "sub-2", "sub-3"}, ids(store.ListOptions{Filters: map[string]string{"user_id": "user-1"}}))
+	assert.Empty(t, ids(store.ListOptions{Filters: map[string]string{"user_id": "user-2"}}))
+	assert.Empty(t, ids(store.ListOptions{Filters: map[string]string{"user_id": "user-3"}}), "the index is reverted with the update")
+	assert.Equal(t, []string{"sub-3"}, ids(store.ListOptions{Filters: map[string]string{"user_id": "user-1", "plan_id": "plan-1"}}))
+
+	_, err = st.Create(ctx, &model.Subscription{UserID: "user-1", PlanID: "plan-3"})
+	assert.ErrorIs(t, err, store.ErrConflict)
+}
+
+func TestSubscriptionStore_SnapshotKeepsTheOrder(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	st := NewSubscriptionStore()
+	for _, id := range []string{"sub-3", "sub-1", "sub-2"} {
+		_, err := st.Create(ctx, &model.Subscription{ID: id})
+		require.NoError(t, err)
+	}
+	_, err := st.Update(ctx, &model.Subscription{ID: "sub-3", PlanID: "plan-1"})
+	require.NoError(t, err)
+
+	// test
+	var buf bytes.Buffer
+	require.NoError(t, st.WriteSnapshot(&buf))
+	restored := NewSubscriptionStore()
+	require.NoError(t, restored.ReadSnapshot(bytes.NewReader(buf.Bytes())))
+
+	// verify
+	for _, s := range []*Subscription{st, restored} {
+		var ids []string
+		for _, sub := range s.store.all() {
+			ids = append(ids, sub.ID)
+		}
+		assert.Equal(t, []string{"sub-3", "sub-1", "sub-2"}, ids, "updates keep the position of the records")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// table keeps the records of a store by ID, in the order they were added, along with indexes on some of their
+// fields. It isn't safe for concurrent use, the stores guard it with their locks.
+type table[T any] struct {
+	id      func(*T) string
+	rows    map[string]*list.Element
+	order   *list.List // of *row[T], oldest first
+	seq     uint64
+	indexes map[string]*index[T]
+}
+
+type row[T any] struct {
+	record *T
+	seq    uint64
+}
+
+// index maps the values of a field, as returned by value, to the IDs of the records having them
+type index[T any] struct {
+	value           func(*T) string
+	caseInsensitive bool
+	ids             map[string]map[string]struct{}
+}
+
+func (ix *index[T]) key(value string) string {
+	if ix.caseInsensitive {
+		return strings.ToLower(value)
+	}
+	return value
+}
+
+// newTable returns an empty table
+func newTable[T any](id func(*T) string) *table[T] {
+	return &table[T]{
+		id:      id,
+		rows:    make(map[string]*list.Element),
+		order:   list.New(),
+		indexes: make(map[string]*index[T]),
+	}
+}
+
+// withIndex adds an index on field to the empty table t, whose values are returned by value
+func (t *table[T]) withIndex(field string, value func(*T) string, caseInsensitive bool) *table[T] {
+	t.indexes[field] = &index[T]{value: value, caseInsensitive: caseInsensitive, ids: make(map[string]map[string]struct{})}
+	return t
+}
+
+func (t *table[T]) len() int {
+	return len(t.rows)
+}
+
+func (t *table[T]) get(id string) (*T, bool) {
+	el, ok := t.rows[id]
+	if !ok {
+		return nil, false
+	}
+	return el.Value.(*row[T]).record, true
+}
+
+// put stores record, replacing the one with the same ID in place, or adding it after the others when there's none
+func (t *table[T]) put(record *T) {
+	id := t.id(record)
+	if el, ok := t.rows[id]; ok {
+		r := el.Value.(*row[T])
+		t.unindex(r.record)
+		r.record = record
+		t.index(record)
+		return
+	}
+
+	t.seq++
+	t.rows[id] = t.order.PushBack(&row[T]{record: record, seq: t.seq})
+	t.index(record)
+}
+
+func (t *table[T]) remove(id string) {
+	el, ok := t.rows[id]
+	if !ok {
+		return
+	}
+	t.unindex(el.Value.(*row[T]).record)
+	t.order.Remove(el)
+	delete(t.rows, id)
+}
+
+// all returns the records in the order they were added
+func (t *table[T]) all() []*T {
+	ret := make([]*T, 0, len(t.rows))
+	for el := t.order.Front(); el != nil; el = el.Next() {
+		ret = append(ret, el.Value.(*row[T]).record)
+	}
+	return ret
+}
+
+// lookup returns the records whose field has the given value, in the order they were added. The field must be
+// indexed.
+func (t *table[T]) lookup(field, value string) []*T {
+	ix := t.indexes[field]
+	ids := ix.ids[ix.key(value)]
+	rows := make([]*row[T], 0, len(ids))
+	for id := range ids {
+		rows = append(rows, t.rows[id].Value.(*row[T]))
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].seq < rows[j].seq })
+
+	ret := make([]*T, len(rows))
+	for i, r := range rows {
+		ret[i] = r.record
+	}
+	return ret
+}
+
+// candidates returns the records that may match the filters of opts, in the order they were added: the ones
+// found through the index of one of the filtered fields, or all of them when no filtered field is indexed.
+// The filters still have to be checked on the returned records.
+func (t *table[T]) candidates(opts store.ListOptions) []*T {
+	for field, value := range opts.Filters {
+		if _, ok := t.indexes[field]; ok {
+			return t.lookup(field, value)
+		}
+	}
+	return t.all()
+}
+
+// reset replaces the records of the table, which are added in the given order
+func (t *table[T]) reset(records []*T) {
+	t.rows = make(map[string]*list.Element, len(records))
+	t.order.Init()
+	for _, ix := range t.indexes {
+		ix.ids = make(map[string]map[string]struct{})
+	}
+	for _, record := range records {
+		t.put(record)
+	}
+}
+
+func (t *table[T]) index(record *T) {
+	id := t.id(record)
+	for _, ix := range t.indexes {
+		key := ix.key(ix.value(record))
+		if ix.ids[key] == nil {
+			ix.ids[key] = make(map[string]struct{})
+		}
+		ix.ids[key][id] = struct{}{}
+	}
+}
+
+func (t *table[T]) unindex(record *T) {
+	id := t.id(record)
+	for _, ix := range t.indexes {
+		key := ix.key(ix.value(record))
+		delete(ix.ids[key], id)
+		if len(ix.ids[key]) == 0 {
+			delete(ix.ids, key)
+		}
+	}
+}
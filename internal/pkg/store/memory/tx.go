@@ -0,0 +1,71 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+type txKey struct{}
+
+// journal keeps the functions undoing the changes made in a transaction, in the order the changes were made
+type journal struct {
+	mu   sync.Mutex
+	undo []func()
+}
+
+func (j *journal) add(undo ...func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.undo = append(j.undo, undo...)
+}
+
+func (j *journal) rollback() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i := len(j.undo) - 1; i >= 0; i-- {
+		j.undo[i]()
+	}
+	j.undo = nil
+}
+
+type transactor struct{}
+
+// NewTransactor returns a store.Transactor for the memory stores. Rolling back a transaction undoes its
+// changes, but the transactions aren't isolated: the changes are seen by other callers as soon as they're made.
+func NewTransactor() store.Transactor {
+	return transactor{}
+}
+
+func (transactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	j := &journal{}
+	txCtx, end := store.MarkTx(context.WithValue(ctx, txKey{}, j))
+	defer end()
+	defer func() {
+		if p := recover(); p != nil {
+			j.rollback()
+			panic(p)
+		}
+		if err != nil {
+			j.rollback()
+			return
+		}
+		// the changes of a nested transaction are undone along with the ones of the outer transaction
+		if parent, ok := ctx.Value(txKey{}).(*journal); ok {
+			parent.add(j.undo...)
+		}
+	}()
+
+	return fn(txCtx)
+}
+
+// onRollback registers undo to be called when the transaction of ctx, if any, is rolled back
+func onRollback(ctx context.Context, undo func()) {
+	if j, ok := ctx.Value(txKey{}).(*journal); ok {
+		j.add(undo)
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/storetest"
+)
+
+func TestTransactor_Conformance(t *testing.T) {
+	storetest.TestTransactor(t, func(*testing.T) storetest.Stores {
+		return storetest.Stores{
+			Tx:    NewTransactor(),
+			Users: NewUserStore(),
+			Plans: NewPlanStore(),
+		}
+	})
+}
@@ -5,44 +5,241 @@ package memory
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
 )
 
-type inMemoryUser struct {
-	store map[string]*model.User
+// User is safe for concurrent use. It keeps its own copies of the users, so changes made by callers
+// to the values they pass in or get back are not seen by the store.
+type User struct {
+	mu    sync.RWMutex
+	store *table[model.User]
 }
 
-func NewUserStore() store.User {
-	return &inMemoryUser{
-		store: make(map[string]*model.User),
+func NewUserStore() *User {
+	return &User{
+		store: newTable(func(u *model.User) string { return u.ID }).
+			withIndex("email", func(u *model.User) string { return u.Email }, true),
 	}
 }
 
-func (u *inMemoryUser) Get(_ context.Context, id string) (*model.User, error) {
-	return u.store[id], nil
+func (u *User) Get(ctx context.Context, id string) (*model.User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	user, ok := u.store.get(id)
+	if !ok || (user.DeletedAt != nil && !store.IncludesDeleted(ctx)) {
+		return nil, fmt.Errorf("user %q: %w", id, store.ErrNotFound)
+	}
+	return copyUser(user), nil
 }
 
-func (u *inMemoryUser) Create(_ context.Context, user *model.User) (*model.User, error) {
-	u.store[user.ID] = user
-	return user, nil
+func (u *User) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	created := copyUser(user)
+	if created.ID == "" {
+		created.ID = store.NewID()
+	}
+	if created.CreatedAt.IsZero() {
+		created.CreatedAt = store.Now()
+	}
+	if created.UpdatedAt.IsZero() {
+		created.UpdatedAt = created.CreatedAt
+	}
+	created.Version = 1
+	created.DeletedAt = nil
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, ok := u.store.get(created.ID); ok {
+		return nil, &store.ConflictError{Kind: "user", ID: created.ID, Reason: "already exists"}
+	}
+	if err := u.checkEmail(created); err != nil {
+		return nil, err
+	}
+	u.store.put(created)
+	onRollback(ctx, func() { u.revert(created.ID, nil) })
+	return copyUser(created), nil
 }
 
-func (u *inMemoryUser) Update(_ context.Context, user *model.User) (*model.User, error) {
-	u.store[user.ID] = user
-	return user, nil
+func (u *User) Update(ctx context.Context, user *model.User) (*model.User, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	current, ok := u.store.get(user.ID)
+	if !ok || current.DeletedAt != nil {
+		return nil, fmt.Errorf("user %q: %w", user.ID, store.ErrNotFound)
+	}
+	if user.Version != 0 && user.Version != current.Version {
+		return nil, fmt.Errorf("user %q is at version %d, not %d: %w", user.ID, current.Version, user.Version, store.ErrVersionMismatch)
+	}
+
+	if err := u.checkEmail(user); err != nil {
+		return nil, err
+	}
+
+	updated := copyUser(user)
+	updated.Version = current.Version + 1
+	updated.CreatedAt = current.CreatedAt
+	updated.UpdatedAt = store.Now()
+	updated.DeletedAt = nil
+	u.store.put(updated)
+	onRollback(ctx, func() { u.revert(current.ID, current) })
+	return copyUser(updated), nil
 }
 
-func (u *inMemoryUser) Delete(_ context.Context, id string) error {
-	delete(u.store, id)
+func (u *User) Delete(ctx context.Context, id string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	current, ok := u.store.get(id)
+	if !ok || current.DeletedAt != nil {
+		return fmt.Errorf("user %q: %w", id, store.ErrNotFound)
+	}
+
+	now := store.Now()
+	deleted := copyUser(current)
+	deleted.Version++
+	deleted.UpdatedAt = now
+	deleted.DeletedAt = &now
+	u.store.put(deleted)
+	onRollback(ctx, func() { u.revert(id, current) })
 	return nil
 }
 
-func (u *inMemoryUser) List(_ context.Context) ([]*model.User, error) {
-	users := make([]*model.User, 0, len(u.store))
-	for _, user := range u.store {
+func (u *User) Restore(ctx context.Context, id string) (*model.User, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	current, ok := u.store.get(id)
+	if !ok {
+		return nil, fmt.Errorf("user %q: %w", id, store.ErrNotFound)
+	}
+	if current.DeletedAt == nil {
+		return copyUser(current), nil
+	}
+	if err := u.checkEmail(current); err != nil {
+		return nil, err
+	}
+
+	restored := copyUser(current)
+	restored.Version++
+	restored.UpdatedAt = store.Now()
+	restored.DeletedAt = nil
+	u.store.put(restored)
+	onRollback(ctx, func() { u.revert(id, current) })
+	return copyUser(restored), nil
+}
+
+func (u *User) List(ctx context.Context, opts store.ListOptions) ([]*model.User, string, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	includeDeleted := store.IncludesDeleted(ctx)
+	users := make([]*model.User, 0, u.store.len())
+	for _, user := range u.store.candidates(opts) {
+		if user.DeletedAt != nil && !includeDeleted {
+			continue
+		}
 		users = append(users, user)
 	}
-	return users, nil
+
+	users, next, err := page(users, opts, store.UserFields)
+	if err != nil {
+		return nil, "", err
+	}
+	for i, user := range users {
+		users[i] = copyUser(user)
+	}
+	return users, next, nil
+}
+
+func (u *User) WriteSnapshot(w io.Writer) error {
+	u.mu.RLock()
+	users := make([]*model.User, 0, u.store.len())
+	for _, user := range u.store.all() {
+		users = append(users, copyUser(user))
+	}
+	u.mu.RUnlock()
+
+	return writeSnapshot(w, users)
+}
+
+func (u *User) ReadSnapshot(r io.Reader) error {
+	users, err := readSnapshot(r, func(user *model.User) string { return user.ID })
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.store.reset(users)
+	return nil
+}
+
+// checkEmail returns an error if another user not deleted has the email of user. It must be called with the lock
+// held.
+func (u *User) checkEmail(user *model.User) error {
+	if user.Email == "" {
+		return nil
+	}
+	for _, other := range u.store.lookup("email", user.Email) {
+		if other.ID != user.ID && other.DeletedAt == nil {
+			return store.EmailInUse(other.ID, other.Email)
+		}
+	}
+	return nil
+}
+
+func (u *User) CountPurgeable(ctx context.Context, rule store.PurgeRule) (int, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	ids, err := purgeable(u.store.all(), rule, store.UserFields, func(u *model.User) *time.Time { return u.DeletedAt })
+	return len(ids), err
+}
+
+func (u *User) Purge(ctx context.Context, rule store.PurgeRule, limit int) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	ids, err := purgeable(u.store.all(), rule, store.UserFields, func(u *model.User) *time.Time { return u.DeletedAt })
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	for _, id := range ids {
+		current, _ := u.store.get(id)
+		u.store.remove(id)
+		onRollback(ctx, func() { u.revert(id, current) })
+	}
+	return len(ids), nil
+}
+
+// revert puts back the user stored under id before a change, removing it when there was none
+func (u *User) revert(id string, previous *model.User) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if previous == nil {
+		u.store.remove(id)
+		return
+	}
+	u.store.put(previous)
+}
+
+func copyUser(user *model.User) *model.User {
+	c := *user
+	if user.DeletedAt != nil {
+		deletedAt := *user.DeletedAt
+		c.DeletedAt = &deletedAt
+	}
+	return &c
 }
@@ -0,0 +1,49 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserStore_Conformance(t *testing.T) {
+	storetest.TestUser(t, func(*testing.T) store.User {
+		return NewUserStore()
+	})
+}
+
+func TestUserStore_EmailIndex(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	st := NewUserStore()
+	_, err := st.Create(ctx, &model.User{ID: "user-1", Email: "John@example.com"})
+	require.NoError(t, err)
+	_, err = st.Create(ctx, &model.User{ID: "user-2", Email: "mary@example.com"})
+	require.NoError(t, err)
+
+	// test
+	_, err = st.Update(ctx, &model.User{ID: "user-2", Email: "jane@example.com"})
+	require.NoError(t, err)
+	_, errReused := st.Create(ctx, &model.User{ID: "user-3", Email: "MARY@example.com"})
+	_, errTaken := st.Create(ctx, &model.User{ID: "user-4", Email: "john@EXAMPLE.com"})
+
+	// verify
+	assert.NoError(t, errReused, "the old email of user-2 is free")
+	assert.ErrorIs(t, errTaken, store.ErrConflict)
+
+	users, _, err := st.List(ctx, store.ListOptions{Filters: map[string]string{"email": "john@example.com"}})
+	require.NoError(t, err)
+	assert.Empty(t, users, "filters are case-sensitive")
+	users, _, err = st.List(ctx, store.ListOptions{Filters: map[string]string{"email": "John@example.com"}})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "user-1", users[0].ID)
+}
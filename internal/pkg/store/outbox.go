@@ -0,0 +1,24 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+)
+
+// Outbox persists the messages to be published for a service. Messages added in a transaction are only seen
+// once it commits, so that they are published if and only if the changes they announce were made.
+type Outbox interface {
+	// Add stores a message to be published, assigning its ID and CreatedAt when they are empty
+	Add(ctx context.Context, msg *model.OutboxMessage) error
+	// Pending returns up to limit messages not sent yet whose next attempt is due at now, oldest first
+	Pending(ctx context.Context, now time.Time, limit int) ([]*model.OutboxMessage, error)
+	// MarkSent records that the message was published, removing it from the outbox
+	MarkSent(ctx context.Context, id string) error
+	// MarkFailed records a failed attempt to publish the message, which is retried after next
+	MarkFailed(ctx context.Context, id string, cause error, next time.Time) error
+}
@@ -9,10 +9,21 @@ import (
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
 )
 
+// Payment persists model.Payment records
 type Payment interface {
 	Get(ctx context.Context, id string) (*model.Payment, error)
 	Create(ctx context.Context, user *model.Payment) (*model.Payment, error)
 	Update(ctx context.Context, user *model.Payment) (*model.Payment, error)
 	Delete(ctx context.Context, id string) error
-	List(ctx context.Context) ([]*model.Payment, error)
+	Restore(ctx context.Context, id string) (*model.Payment, error)
+	List(ctx context.Context, opts ListOptions) ([]*model.Payment, string, error)
+}
+
+// PaymentFields are the fields payments can be sorted and filtered by
+var PaymentFields = Fields[model.Payment]{
+	"id":              func(p *model.Payment) any { return p.ID },
+	"subscription_id": func(p *model.Payment) any { return p.SubscriptionID },
+	"status":          func(p *model.Payment) any { return p.Status },
+	"created_at":      func(p *model.Payment) any { return p.CreatedAt },
+	"updated_at":      func(p *model.Payment) any { return p.UpdatedAt },
 }
@@ -6,10 +6,20 @@ import (
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
 )
 
+// Plan persists model.Plan records
 type Plan interface {
 	Get(ctx context.Context, id string) (*model.Plan, error)
 	Create(ctx context.Context, plan *model.Plan) (*model.Plan, error)
 	Update(ctx context.Context, plan *model.Plan) (*model.Plan, error)
 	Delete(ctx context.Context, id string) error
-	List(ctx context.Context) ([]*model.Plan, error)
+	Restore(ctx context.Context, id string) (*model.Plan, error)
+	List(ctx context.Context, opts ListOptions) ([]*model.Plan, string, error)
+}
+
+// PlanFields are the fields plans can be sorted and filtered by
+var PlanFields = Fields[model.Plan]{
+	"id":         func(p *model.Plan) any { return p.ID },
+	"name":       func(p *model.Plan) any { return p.Name },
+	"created_at": func(p *model.Plan) any { return p.CreatedAt },
+	"updated_at": func(p *model.Plan) any { return p.UpdatedAt },
 }
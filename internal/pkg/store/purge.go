@@ -0,0 +1,38 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PurgeRule selects the records to be removed for good
+type PurgeRule struct {
+	// Deleted selects the soft-deleted records deleted before Before. When false, the records last updated
+	// before Before are selected, whether deleted or not.
+	Deleted bool
+	Before  time.Time
+
+	// Filters restricts the records to the ones where the named fields have the given values, as in ListOptions
+	Filters map[string]string
+}
+
+// Purger permanently removes records, bypassing the soft delete. It's implemented by the stores of this module
+// besides their store interface, and isn't meant to be decorated.
+type Purger interface {
+	// CountPurgeable returns the number of records selected by rule
+	CountPurgeable(ctx context.Context, rule PurgeRule) (int, error)
+	// Purge removes up to limit records selected by rule, in the order of their IDs, returning how many were removed
+	Purge(ctx context.Context, rule PurgeRule, limit int) (int, error)
+}
+
+// CheckPurgeRule validates the rule against the fields known for the records
+func CheckPurgeRule[T any](rule PurgeRule, fields Fields[T]) error {
+	if rule.Before.IsZero() {
+		return fmt.Errorf("purge rule without a time limit: %w", ErrInvalid)
+	}
+	return Check(ListOptions{Filters: rule.Filters}, fields)
+}
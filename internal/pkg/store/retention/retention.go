@@ -0,0 +1,205 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+// Package retention purges the records kept past their retention period. A job runs its rules periodically,
+// removing the records in batches, each in a transaction of its own, so that the database isn't locked for
+// long. Purges bypass the store decorators: they are neither audited nor announced as events.
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+const (
+	// DefaultInterval is how often a job runs, when Options.Interval is zero
+	DefaultInterval = time.Hour
+	// DefaultBatchSize is the number of records removed per transaction, when Options.BatchSize is zero
+	DefaultBatchSize = 500
+)
+
+// Rule selects records to be purged: the ones matching Filters that were deleted more than Age ago, or last
+// updated more than Age ago when Deleted is false
+type Rule struct {
+	// Name identifies the rule in reports and logs
+	Name    string
+	Deleted bool
+	Age     time.Duration
+	Filters map[string]string
+}
+
+// Options configures a job
+type Options struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+// Report tells how many records a run purged, or would purge for dry runs
+type Report struct {
+	Resource string       `json:"resource"`
+	DryRun   bool         `json:"dry_run"`
+	At       time.Time    `json:"at"`
+	Rules    []RuleReport `json:"rules"`
+	Total    int          `json:"total"`
+}
+
+// RuleReport tells how many records a rule selected
+type RuleReport struct {
+	Rule string `json:"rule"`
+	// Before is the time the records were deleted or last updated before
+	Before  time.Time `json:"before"`
+	Records int       `json:"records"`
+}
+
+// Job purges the records of a store selected by its rules
+type Job struct {
+	resource string
+	purger   store.Purger
+	tx       store.Transactor
+	rules    []Rule
+	opts     Options
+	now      func() time.Time
+
+	mu   sync.Mutex
+	last *Report
+
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+}
+
+// NewJob returns a job purging the records of the given resource, like "user", which is started by Start. The
+// transactor must run its transactions on the store of purger.
+func NewJob(resource string, purger store.Purger, tx store.Transactor, rules []Rule, opts Options) *Job {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	return &Job{
+		resource: resource,
+		purger:   purger,
+		tx:       tx,
+		rules:    rules,
+		opts:     opts,
+		now:      store.Now,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the job every Options.Interval, until it's closed
+func (j *Job) Start() {
+	j.startOnce.Do(func() { go j.loop() })
+}
+
+func (j *Job) loop() {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.stop:
+			return
+		case <-ticker.C:
+			if _, err := j.Run(context.Background()); err != nil {
+				slog.Error("failed to purge records", "resource", j.resource, "error", err)
+			}
+		}
+	}
+}
+
+// Run purges the records selected by the rules, returning how many were purged. When a batch fails, the
+// records purged by the previous ones stay purged, and are counted in the returned report.
+func (j *Job) Run(ctx context.Context) (*Report, error) {
+	report := j.newReport(false)
+	var err error
+	for i, rule := range j.rules {
+		report.Rules[i].Records, err = j.purge(ctx, j.storeRule(rule, report.At))
+		report.Total += report.Rules[i].Records
+		if err != nil {
+			break
+		}
+	}
+
+	j.mu.Lock()
+	j.last = report
+	j.mu.Unlock()
+
+	if report.Total > 0 {
+		slog.Info("purged records", "resource", j.resource, "count", report.Total)
+	}
+	return report, err
+}
+
+// purge removes the records selected by rule in batches, returning how many were removed
+func (j *Job) purge(ctx context.Context, rule store.PurgeRule) (int, error) {
+	total := 0
+	for {
+		var purged int
+		err := j.tx.WithTx(ctx, func(ctx context.Context) error {
+			var err error
+			purged, err = j.purger.Purge(ctx, rule, j.opts.BatchSize)
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+		total += purged
+		if purged < j.opts.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// DryRun returns how many records a run would purge now, without purging them
+func (j *Job) DryRun(ctx context.Context) (*Report, error) {
+	report := j.newReport(true)
+	for i, rule := range j.rules {
+		n, err := j.purger.CountPurgeable(ctx, j.storeRule(rule, report.At))
+		if err != nil {
+			return nil, err
+		}
+		report.Rules[i].Records = n
+		report.Total += n
+	}
+	return report, nil
+}
+
+// LastRun returns the report of the last run, nil when the job never ran
+func (j *Job) LastRun() *Report {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.last
+}
+
+func (j *Job) newReport(dryRun bool) *Report {
+	report := &Report{Resource: j.resource, DryRun: dryRun, At: j.now(), Rules: make([]RuleReport, len(j.rules))}
+	for i, rule := range j.rules {
+		report.Rules[i] = RuleReport{Rule: rule.Name, Before: report.At.Add(-rule.Age)}
+	}
+	return report
+}
+
+func (j *Job) storeRule(rule Rule, now time.Time) store.PurgeRule {
+	return store.PurgeRule{Deleted: rule.Deleted, Before: now.Add(-rule.Age), Filters: rule.Filters}
+}
+
+// Close stops the job, waiting for a run in progress. It's safe to call more than once, and on jobs never
+// started.
+func (j *Job) Close() error {
+	j.closeOnce.Do(func() {
+		// a job not started yet can't be started anymore
+		j.startOnce.Do(func() { close(j.done) })
+		close(j.stop)
+		<-j.done
+	})
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package retention
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJob_Run(t *testing.T) {
+	// prepare
+	ctx := context.Background()
+	st := memory.NewUserStore()
+	for i := range 5 {
+		_, err := st.Create(ctx, &model.User{ID: fmt.Sprintf("user-%d", i)})
+		require.NoError(t, err)
+		if i > 0 {
+			require.NoError(t, st.Delete(ctx, fmt.Sprintf("user-%d", i)))
+		}
+	}
+
+	job := NewJob("user", st, memory.NewTransactor(), []Rule{{Name: "deleted", Deleted: true, Age: time.Hour}}, Options{BatchSize: 3})
+	now := store.Now()
+	job.now = func() time.Time { return now }
+
+	// test: nothing is old enough
+	report, err := job.Run(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, report.Total)
+
+	// test: the dry run counts without purging
+	now = now.Add(2 * time.Hour)
+	report, err = job.DryRun(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 4, report.Total)
+	assert.Equal(t, []RuleReport{{Rule: "deleted", Before: now.Add(-time.Hour), Records: 4}}, report.Rules)
+
+	// test: purged in two batches
+	report, err = job.Run(ctx)
+
+	// verify
+	require.NoError(t, err)
+	assert.False(t, report.DryRun)
+	assert.Equal(t, 4, report.Total)
+	assert.Equal(t, report, job.LastRun())
+
+	users, _, err := st.List(store.WithDeleted(ctx), store.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "user-0", users[0].ID)
+}
+
+func TestJob_Close(t *testing.T) {
+	job := NewJob("user", memory.NewUserStore(), memory.NewTransactor(), nil, Options{})
+	assert.Nil(t, job.LastRun())
+	assert.NoError(t, job.Close())
+	assert.NoError(t, job.Close())
+
+	started := NewJob("user", memory.NewUserStore(), memory.NewTransactor(), nil, Options{})
+	started.Start()
+	assert.NoError(t, started.Close())
+}
@@ -0,0 +1,84 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAudit runs the conformance tests for store.Audit implementations against the stores returned by newStore,
+// which must be empty
+func TestAudit(t *testing.T, newStore func(t *testing.T) store.Audit) {
+	ctx := context.Background()
+
+	entry := func(resourceID, action string) *model.AuditEntry {
+		return &model.AuditEntry{
+			Resource:   "plan",
+			ResourceID: resourceID,
+			Action:     action,
+			Actor:      "john",
+			RequestID:  "req-1",
+			Changes:    []model.FieldChange{{Field: "name", Before: json.RawMessage(`"Basic"`), After: json.RawMessage(`"Premium"`)}},
+		}
+	}
+
+	t.Run("record assigns the id and timestamp", func(t *testing.T) {
+		st := newStore(t)
+		recorded := entry("plan-1", model.AuditUpdate)
+
+		require.NoError(t, st.Record(ctx, recorded))
+
+		history, err := st.History(ctx, "plan", "plan-1")
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.NotEmpty(t, history[0].ID)
+		assert.False(t, history[0].Timestamp.IsZero())
+		assert.Equal(t, "john", history[0].Actor)
+		assert.Equal(t, "req-1", history[0].RequestID)
+		assert.Equal(t, model.AuditUpdate, history[0].Action)
+		require.Len(t, history[0].Changes, 1)
+		assert.Equal(t, "name", history[0].Changes[0].Field)
+		assert.JSONEq(t, `"Basic"`, string(history[0].Changes[0].Before))
+		assert.JSONEq(t, `"Premium"`, string(history[0].Changes[0].After))
+	})
+
+	t.Run("history is in order and by record", func(t *testing.T) {
+		st := newStore(t)
+		for _, e := range []*model.AuditEntry{
+			entry("plan-1", model.AuditCreate),
+			entry("plan-2", model.AuditCreate),
+			entry("plan-1", model.AuditUpdate),
+			entry("plan-1", model.AuditDelete),
+		} {
+			require.NoError(t, st.Record(ctx, e))
+		}
+
+		history, err := st.History(ctx, "plan", "plan-1")
+
+		require.NoError(t, err)
+		var actions []string
+		for _, e := range history {
+			assert.Equal(t, "plan-1", e.ResourceID)
+			actions = append(actions, e.Action)
+		}
+		assert.Equal(t, []string{model.AuditCreate, model.AuditUpdate, model.AuditDelete}, actions)
+	})
+
+	t.Run("history of another resource", func(t *testing.T) {
+		st := newStore(t)
+		require.NoError(t, st.Record(ctx, entry("1", model.AuditCreate)))
+
+		history, err := st.History(ctx, "user", "1")
+
+		require.NoError(t, err)
+		assert.Empty(t, history)
+	})
+}
@@ -0,0 +1,123 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// OutboxStores are an outbox along with the transactor running transactions on it
+type OutboxStores struct {
+	Tx     store.Transactor
+	Outbox store.Outbox
+}
+
+// TestOutbox runs the conformance tests for store.Outbox implementations against the stores returned by
+// newStores, which must be empty
+func TestOutbox(t *testing.T, newStores func(t *testing.T) OutboxStores) {
+	ctx := context.Background()
+
+	// pending returns the IDs of the pending messages at the given time
+	pending := func(t *testing.T, st store.Outbox, now time.Time) []string {
+		msgs, err := st.Pending(ctx, now, 10)
+		require.NoError(t, err)
+		var ids []string
+		for _, msg := range msgs {
+			ids = append(ids, msg.ID)
+		}
+		return ids
+	}
+
+	t.Run("add", func(t *testing.T) {
+		st := newStores(t).Outbox
+		msg := &model.OutboxMessage{Subject: "plans.plan.created", Headers: map[string]string{"X-Actor": "john"}, Data: []byte(`{"id":"1"}`)}
+
+		require.NoError(t, st.Add(ctx, msg))
+
+		msgs, err := st.Pending(ctx, store.Now(), 10)
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		assert.NotEmpty(t, msgs[0].ID)
+		assert.Empty(t, msg.ID, "the message passed to add was changed")
+		assert.False(t, msgs[0].CreatedAt.IsZero())
+		assert.Equal(t, "plans.plan.created", msgs[0].Subject)
+		assert.Equal(t, map[string]string{"X-Actor": "john"}, msgs[0].Headers)
+		assert.Equal(t, `{"id":"1"}`, string(msgs[0].Data))
+		assert.Zero(t, msgs[0].Attempts)
+		assert.Nil(t, msgs[0].SentAt)
+	})
+
+	t.Run("pending in order, up to the limit", func(t *testing.T) {
+		st := newStores(t).Outbox
+		for _, id := range []string{"msg-1", "msg-2", "msg-3"} {
+			require.NoError(t, st.Add(ctx, &model.OutboxMessage{ID: id, Subject: "s"}))
+		}
+
+		msgs, err := st.Pending(ctx, store.Now(), 2)
+
+		require.NoError(t, err)
+		require.Len(t, msgs, 2)
+		assert.Equal(t, "msg-1", msgs[0].ID)
+		assert.Equal(t, "msg-2", msgs[1].ID)
+	})
+
+	t.Run("sent messages aren't pending", func(t *testing.T) {
+		st := newStores(t).Outbox
+		require.NoError(t, st.Add(ctx, &model.OutboxMessage{ID: "msg-1", Subject: "s"}))
+		require.NoError(t, st.Add(ctx, &model.OutboxMessage{ID: "msg-2", Subject: "s"}))
+
+		require.NoError(t, st.MarkSent(ctx, "msg-1"))
+
+		assert.Equal(t, []string{"msg-2"}, pending(t, st, store.Now()))
+		assert.ErrorIs(t, st.MarkSent(ctx, "msg-1"), store.ErrNotFound, "sent messages are removed")
+	})
+
+	t.Run("failed messages are retried later", func(t *testing.T) {
+		st := newStores(t).Outbox
+		require.NoError(t, st.Add(ctx, &model.OutboxMessage{ID: "msg-1", Subject: "s"}))
+		next := store.Now().Add(time.Minute)
+
+		require.NoError(t, st.MarkFailed(ctx, "msg-1", errors.New("boom"), next))
+
+		assert.Empty(t, pending(t, st, store.Now()))
+		msgs, err := st.Pending(ctx, next, 10)
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		assert.Equal(t, 1, msgs[0].Attempts)
+		assert.Equal(t, "boom", msgs[0].LastError)
+	})
+
+	t.Run("mark unknown", func(t *testing.T) {
+		st := newStores(t).Outbox
+
+		errSent := st.MarkSent(ctx, "unknown")
+		errFailed := st.MarkFailed(ctx, "unknown", errors.New("boom"), store.Now())
+
+		assert.ErrorIs(t, errSent, store.ErrNotFound)
+		assert.ErrorIs(t, errFailed, store.ErrNotFound)
+	})
+
+	t.Run("messages added in a rolled back transaction are dropped", func(t *testing.T) {
+		st := newStores(t)
+		errBoom := errors.New("boom")
+
+		err := st.Tx.WithTx(ctx, func(ctx context.Context) error {
+			if err := st.Outbox.Add(ctx, &model.OutboxMessage{ID: "msg-1", Subject: "s"}); err != nil {
+				return err
+			}
+			return errBoom
+		})
+
+		assert.ErrorIs(t, err, errBoom)
+		assert.Empty(t, pending(t, st.Outbox, store.Now()))
+	})
+}
@@ -0,0 +1,28 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// TestPayment runs the conformance tests for store.Payment implementations against the stores returned by newStore,
+// which must be empty
+func TestPayment(t *testing.T, newStore func(t *testing.T) store.Payment) {
+	run(t, func(t *testing.T) crud[model.Payment] { return newStore(t) }, fixture[model.Payment]{
+		fields: store.PaymentFields,
+		field:  "status",
+		newRecord: func(id, value string) *model.Payment {
+			return &model.Payment{ID: id, Status: value, SubscriptionID: "subscription-1", Amount: 10}
+		},
+		setValue:   func(p *model.Payment, value string) { p.Status = value },
+		version:    func(p *model.Payment) int64 { return p.Version },
+		setVersion: func(p *model.Payment, version int64) { p.Version = version },
+		deletedAt:  func(p *model.Payment) *time.Time { return p.DeletedAt },
+	})
+}
@@ -0,0 +1,28 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// TestPlan runs the conformance tests for store.Plan implementations against the stores returned by newStore,
+// which must be empty
+func TestPlan(t *testing.T, newStore func(t *testing.T) store.Plan) {
+	run(t, func(t *testing.T) crud[model.Plan] { return newStore(t) }, fixture[model.Plan]{
+		fields: store.PlanFields,
+		field:  "name",
+		newRecord: func(id, value string) *model.Plan {
+			return &model.Plan{ID: id, Name: value, Price: 10}
+		},
+		setValue:   func(p *model.Plan, value string) { p.Name = value },
+		version:    func(p *model.Plan) int64 { return int64(p.Version) },
+		setVersion: func(p *model.Plan, version int64) { p.Version = int32(version) },
+		deletedAt:  func(p *model.Plan) *time.Time { return p.DeletedAt },
+	})
+}
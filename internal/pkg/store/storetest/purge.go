@@ -0,0 +1,129 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// PurgeStores are a plan store implementing store.Purger, along with the transactor running transactions on it
+type PurgeStores struct {
+	Tx    store.Transactor
+	Plans interface {
+		store.Plan
+		store.Purger
+	}
+}
+
+// TestPurge runs the conformance tests for store.Purger implementations against the plan stores returned by
+// newStores, which must be empty
+func TestPurge(t *testing.T, newStores func(t *testing.T) PurgeStores) {
+	ctx := context.Background()
+
+	// create adds plans with the given IDs and name, deleting the ones in deleted
+	create := func(t *testing.T, st store.Plan, name string, ids []string, deleted ...string) {
+		for _, id := range ids {
+			_, err := st.Create(ctx, &model.Plan{ID: id, Name: name})
+			require.NoError(t, err)
+		}
+		for _, id := range deleted {
+			require.NoError(t, st.Delete(ctx, id))
+		}
+	}
+
+	// remaining returns the IDs of the plans left, deleted or not
+	remaining := func(t *testing.T, st store.Plan) []string {
+		plans, _, err := st.List(store.WithDeleted(ctx), store.ListOptions{})
+		require.NoError(t, err)
+		var ids []string
+		for _, plan := range plans {
+			ids = append(ids, plan.ID)
+		}
+		return ids
+	}
+
+	t.Run("deleted records, in batches", func(t *testing.T) {
+		st := newStores(t).Plans
+		create(t, st, "Basic", []string{"plan-1", "plan-2", "plan-3"}, "plan-3", "plan-1")
+		rule := store.PurgeRule{Deleted: true, Before: store.Now().Add(time.Hour)}
+
+		n, err := st.CountPurgeable(ctx, rule)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		purged, err := st.Purge(ctx, rule, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+		assert.Equal(t, []string{"plan-2", "plan-3"}, remaining(t, st), "purged in the order of the IDs")
+
+		purged, err = st.Purge(ctx, rule, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+		purged, err = st.Purge(ctx, rule, 10)
+		require.NoError(t, err)
+		assert.Zero(t, purged)
+
+		assert.Equal(t, []string{"plan-2"}, remaining(t, st))
+		_, err = st.Get(store.WithDeleted(ctx), "plan-1")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("only records deleted before the limit", func(t *testing.T) {
+		st := newStores(t).Plans
+		create(t, st, "Basic", []string{"plan-1"}, "plan-1")
+
+		n, err := st.CountPurgeable(ctx, store.PurgeRule{Deleted: true, Before: store.Now().Add(-time.Hour)})
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("stale records matching the filters", func(t *testing.T) {
+		st := newStores(t).Plans
+		create(t, st, "Basic", []string{"plan-1", "plan-2"}, "plan-2")
+		create(t, st, "Premium", []string{"plan-3"})
+		rule := store.PurgeRule{Before: store.Now().Add(time.Hour), Filters: map[string]string{"name": "Basic"}}
+
+		n, err := st.CountPurgeable(ctx, rule)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n, "deleted or not")
+
+		purged, err := st.Purge(ctx, rule, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 2, purged)
+		assert.Equal(t, []string{"plan-3"}, remaining(t, st))
+	})
+
+	t.Run("rolled back", func(t *testing.T) {
+		stores := newStores(t)
+		create(t, stores.Plans, "Basic", []string{"plan-1"}, "plan-1")
+		errBoom := errors.New("boom")
+
+		err := stores.Tx.WithTx(ctx, func(ctx context.Context) error {
+			purged, err := stores.Plans.Purge(ctx, store.PurgeRule{Deleted: true, Before: store.Now().Add(time.Hour)}, 10)
+			require.NoError(t, err)
+			require.Equal(t, 1, purged)
+			return errBoom
+		})
+
+		assert.ErrorIs(t, err, errBoom)
+		assert.Equal(t, []string{"plan-1"}, remaining(t, stores.Plans))
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		st := newStores(t).Plans
+
+		_, err := st.CountPurgeable(ctx, store.PurgeRule{Deleted: true})
+		assert.ErrorIs(t, err, store.ErrInvalid)
+		_, err = st.Purge(ctx, store.PurgeRule{Before: store.Now(), Filters: map[string]string{"price": "10"}}, 10)
+		assert.ErrorIs(t, err, store.ErrInvalid)
+	})
+}
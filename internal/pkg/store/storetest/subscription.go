@@ -0,0 +1,61 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubscription runs the conformance tests for store.Subscription implementations against the stores returned by newStore,
+// which must be empty
+func TestSubscription(t *testing.T, newStore func(t *testing.T) store.Subscription) {
+	run(t, func(t *testing.T) crud[model.Subscription] { return newStore(t) }, fixture[model.Subscription]{
+		fields: store.SubscriptionFields,
+		field:  "user_id",
+		newRecord: func(id, value string) *model.Subscription {
+			return &model.Subscription{ID: id, UserID: value, PlanID: "plan-" + id}
+		},
+		setValue:   func(s *model.Subscription, value string) { s.UserID = value },
+		version:    func(s *model.Subscription) int64 { return s.Version },
+		setVersion: func(s *model.Subscription, version int64) { s.Version = version },
+		deletedAt:  func(s *model.Subscription) *time.Time { return s.DeletedAt },
+	})
+	t.Run("users subscribe once to each plan", func(t *testing.T) {
+		ctx := context.Background()
+		st := newStore(t)
+		_, err := st.Create(ctx, &model.Subscription{ID: "sub-1", UserID: "user-1", PlanID: "plan-1"})
+		require.NoError(t, err)
+		_, err = st.Create(ctx, &model.Subscription{ID: "sub-2", UserID: "user-1", PlanID: "plan-2"})
+		require.NoError(t, err)
+
+		_, errCreate := st.Create(ctx, &model.Subscription{ID: "sub-3", UserID: "user-1", PlanID: "plan-1"})
+		_, errUpdate := st.Update(ctx, &model.Subscription{ID: "sub-2", UserID: "user-1", PlanID: "plan-1"})
+		_, errOtherUser := st.Create(ctx, &model.Subscription{ID: "sub-4", UserID: "user-2", PlanID: "plan-1"})
+
+		assertConflict(t, errCreate, "sub-1")
+		assertConflict(t, errUpdate, "sub-1")
+		assert.NoError(t, errOtherUser)
+	})
+
+	t.Run("users subscribe again once deleted", func(t *testing.T) {
+		ctx := context.Background()
+		st := newStore(t)
+		_, err := st.Create(ctx, &model.Subscription{ID: "sub-1", UserID: "user-1", PlanID: "plan-1"})
+		require.NoError(t, err)
+		require.NoError(t, st.Delete(ctx, "sub-1"))
+
+		_, errCreate := st.Create(ctx, &model.Subscription{ID: "sub-2", UserID: "user-1", PlanID: "plan-1"})
+		_, errRestore := st.Restore(ctx, "sub-1")
+
+		assert.NoError(t, errCreate)
+		assertConflict(t, errRestore, "sub-2")
+	})
+}
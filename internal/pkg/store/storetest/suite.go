@@ -0,0 +1,418 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+// Package storetest has the conformance tests for the implementations of the store interfaces. Implementations
+// run them from their own tests, passing a function that returns a new, empty store.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// crud is the shape shared by all the store interfaces
+type crud[T any] interface {
+	Get(ctx context.Context, id string) (*T, error)
+	Create(ctx context.Context, record *T) (*T, error)
+	Update(ctx context.Context, record *T) (*T, error)
+	Delete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) (*T, error)
+	List(ctx context.Context, opts store.ListOptions) ([]*T, string, error)
+}
+
+// fixture tells the suite how to build and inspect the records of a store
+type fixture[T any] struct {
+	fields store.Fields[T]
+
+	// field is a string field the suite writes to, and then sorts and filters by
+	field string
+
+	// newRecord returns a record without server fields, with the given ID and value for field
+	newRecord func(id, value string) *T
+
+	setValue   func(record *T, value string)
+	version    func(record *T) int64
+	setVersion func(record *T, version int64)
+	deletedAt  func(record *T) *time.Time
+}
+
+func (f fixture[T]) id(record *T) string {
+	return f.fields["id"](record).(string)
+}
+
+func (f fixture[T]) value(record *T) string {
+	return f.fields[f.field](record).(string)
+}
+
+func (f fixture[T]) createdAt(record *T) time.Time {
+	return f.fields["created_at"](record).(time.Time)
+}
+
+func (f fixture[T]) updatedAt(record *T) time.Time {
+	return f.fields["updated_at"](record).(time.Time)
+}
+
+// run runs the conformance tests, each of them against a new store
+func run[T any](t *testing.T, newStore func(t *testing.T) crud[T], f fixture[T]) {
+	ctx := context.Background()
+
+	// create creates a record, failing the test on errors
+	create := func(t *testing.T, st crud[T], id, value string) *T {
+		created, err := st.Create(ctx, f.newRecord(id, value))
+		require.NoError(t, err)
+		return created
+	}
+
+	t.Run("create assigns the server fields", func(t *testing.T) {
+		st := newStore(t)
+		record := f.newRecord("", "a")
+
+		created, err := st.Create(ctx, record)
+
+		require.NoError(t, err)
+		assert.NotEmpty(t, f.id(created))
+		assert.Equal(t, "a", f.value(created))
+		assert.EqualValues(t, 1, f.version(created))
+		assert.False(t, f.createdAt(created).IsZero())
+		assert.True(t, f.updatedAt(created).Equal(f.createdAt(created)))
+		assert.Nil(t, f.deletedAt(created))
+		assert.Empty(t, f.id(record), "the record passed to create was changed")
+	})
+
+	t.Run("create keeps the given id", func(t *testing.T) {
+		st := newStore(t)
+
+		created := create(t, st, "r-1", "a")
+
+		assert.Equal(t, "r-1", f.id(created))
+	})
+
+	t.Run("create ignores the given version", func(t *testing.T) {
+		st := newStore(t)
+		record := f.newRecord("r-1", "a")
+		f.setVersion(record, 42)
+
+		created, err := st.Create(ctx, record)
+
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, f.version(created))
+	})
+
+	t.Run("create existing", func(t *testing.T) {
+		st := newStore(t)
+		create(t, st, "r-1", "a")
+
+		created, err := st.Create(ctx, f.newRecord("r-1", "b"))
+
+		assertConflict(t, err, "r-1")
+		assert.Nil(t, created)
+	})
+
+	t.Run("create over a deleted record", func(t *testing.T) {
+		st := newStore(t)
+		create(t, st, "r-1", "a")
+		require.NoError(t, st.Delete(ctx, "r-1"))
+
+		_, err := st.Create(ctx, f.newRecord("r-1", "b"))
+
+		assert.ErrorIs(t, err, store.ErrConflict)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		st := newStore(t)
+		created := create(t, st, "r-1", "a")
+
+		got, err := st.Get(ctx, "r-1")
+
+		require.NoError(t, err)
+		assert.Equal(t, "r-1", f.id(got))
+		assert.Equal(t, "a", f.value(got))
+		assert.Equal(t, f.version(created), f.version(got))
+		assert.True(t, f.createdAt(created).Equal(f.createdAt(got)))
+		assert.Nil(t, f.deletedAt(got))
+	})
+
+	t.Run("get missing", func(t *testing.T) {
+		st := newStore(t)
+
+		got, err := st.Get(ctx, "missing")
+
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.Nil(t, got)
+	})
+
+	t.Run("returns copies", func(t *testing.T) {
+		st := newStore(t)
+		record := f.newRecord("r-1", "a")
+		created, err := st.Create(ctx, record)
+		require.NoError(t, err)
+		got, err := st.Get(ctx, "r-1")
+		require.NoError(t, err)
+		listed, _, err := st.List(ctx, store.ListOptions{})
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+
+		for _, r := range []*T{record, created, got, listed[0]} {
+			f.setValue(r, "changed by the caller")
+		}
+
+		got, err = st.Get(ctx, "r-1")
+		require.NoError(t, err)
+		assert.Equal(t, "a", f.value(got))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		st := newStore(t)
+		created := create(t, st, "r-1", "a")
+		f.setValue(created, "b")
+
+		updated, err := st.Update(ctx, created)
+
+		require.NoError(t, err)
+		assert.Equal(t, "b", f.value(updated))
+		assert.EqualValues(t, 2, f.version(updated))
+		assert.True(t, f.createdAt(created).Equal(f.createdAt(updated)))
+		assert.False(t, f.updatedAt(updated).Before(f.updatedAt(created)))
+
+		got, err := st.Get(ctx, "r-1")
+		require.NoError(t, err)
+		assert.Equal(t, "b", f.value(got))
+		assert.EqualValues(t, 2, f.version(got))
+	})
+
+	t.Run("update without a version", func(t *testing.T) {
+		st := newStore(t)
+		create(t, st, "r-1", "a")
+		record := f.newRecord("r-1", "b")
+
+		updated, err := st.Update(ctx, record)
+
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, f.version(updated))
+	})
+
+	t.Run("update stale version", func(t *testing.T) {
+		st := newStore(t)
+		created := create(t, st, "r-1", "a")
+		f.setValue(created, "b")
+		_, err := st.Update(ctx, created)
+		require.NoError(t, err)
+
+		f.setValue(created, "c")
+		updated, err := st.Update(ctx, created)
+
+		assert.ErrorIs(t, err, store.ErrVersionMismatch)
+		assert.Nil(t, updated)
+		got, err := st.Get(ctx, "r-1")
+		require.NoError(t, err)
+		assert.Equal(t, "b", f.value(got))
+	})
+
+	t.Run("update missing", func(t *testing.T) {
+		st := newStore(t)
+
+		updated, err := st.Update(ctx, f.newRecord("missing", "a"))
+
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.Nil(t, updated)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		st := newStore(t)
+		create(t, st, "r-1", "a")
+
+		err := st.Delete(ctx, "r-1")
+
+		require.NoError(t, err)
+		_, err = st.Get(ctx, "r-1")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		_, err = st.Update(ctx, f.newRecord("r-1", "b"))
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.ErrorIs(t, st.Delete(ctx, "r-1"), store.ErrNotFound)
+
+		deleted, err := st.Get(store.WithDeleted(ctx), "r-1")
+		require.NoError(t, err)
+		assert.NotNil(t, f.deletedAt(deleted))
+		assert.EqualValues(t, 2, f.version(deleted))
+	})
+
+	t.Run("delete missing", func(t *testing.T) {
+		st := newStore(t)
+
+		err := st.Delete(ctx, "missing")
+
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		st := newStore(t)
+		create(t, st, "r-1", "a")
+		require.NoError(t, st.Delete(ctx, "r-1"))
+
+		restored, err := st.Restore(ctx, "r-1")
+
+		require.NoError(t, err)
+		assert.Nil(t, f.deletedAt(restored))
+		assert.EqualValues(t, 3, f.version(restored))
+		got, err := st.Get(ctx, "r-1")
+		require.NoError(t, err)
+		assert.Equal(t, "a", f.value(got))
+	})
+
+	t.Run("restore not deleted", func(t *testing.T) {
+		st := newStore(t)
+		create(t, st, "r-1", "a")
+
+		restored, err := st.Restore(ctx, "r-1")
+
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, f.version(restored))
+	})
+
+	t.Run("restore missing", func(t *testing.T) {
+		st := newStore(t)
+
+		restored, err := st.Restore(ctx, "missing")
+
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.Nil(t, restored)
+	})
+
+	t.Run("concurrent access", func(t *testing.T) {
+		st := newStore(t)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				id := fmt.Sprintf("r-%02d", i)
+				_, err := st.Create(ctx, f.newRecord(id, "a"))
+				assert.NoError(t, err)
+				_, err = st.Update(ctx, f.newRecord(id, "b"))
+				assert.NoError(t, err)
+				_, err = st.Get(ctx, id)
+				assert.NoError(t, err)
+				_, _, err = st.List(ctx, store.ListOptions{})
+				assert.NoError(t, err)
+				if i%2 == 0 {
+					assert.NoError(t, st.Delete(ctx, id))
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		records, _, err := st.List(ctx, store.ListOptions{PageSize: 100})
+		require.NoError(t, err)
+		assert.Len(t, records, 10)
+		for _, r := range records {
+			assert.Equal(t, "b", f.value(r))
+		}
+	})
+
+	t.Run("list empty", func(t *testing.T) {
+		st := newStore(t)
+
+		records, next, err := st.List(ctx, store.ListOptions{})
+
+		require.NoError(t, err)
+		assert.Empty(t, records)
+		assert.Empty(t, next)
+	})
+
+	t.Run("list pages", func(t *testing.T) {
+		st := newStore(t)
+		for i := 5; i > 0; i-- {
+			create(t, st, fmt.Sprintf("r-%d", i), "a")
+		}
+
+		var ids []string
+		opts := store.ListOptions{PageSize: 2}
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 3, "pagination doesn't end")
+			records, next, err := st.List(ctx, opts)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(records), 2)
+			for _, r := range records {
+				ids = append(ids, f.id(r))
+			}
+			if next == "" {
+				break
+			}
+			opts.Cursor = next
+		}
+
+		assert.Equal(t, []string{"r-1", "r-2", "r-3", "r-4", "r-5"}, ids)
+	})
+
+	t.Run("list sorts and filters", func(t *testing.T) {
+		st := newStore(t)
+		create(t, st, "r-1", "b")
+		create(t, st, "r-2", "a")
+		create(t, st, "r-3", "b")
+		create(t, st, "r-4", "c")
+
+		sorted, _, err := st.List(ctx, store.ListOptions{Sort: "-" + f.field})
+		require.NoError(t, err)
+		filtered, _, err := st.List(ctx, store.ListOptions{Filters: map[string]string{f.field: "b"}})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"r-4", "r-3", "r-1", "r-2"}, ids(f, sorted))
+		assert.Equal(t, []string{"r-1", "r-3"}, ids(f, filtered))
+	})
+
+	t.Run("list skips deleted", func(t *testing.T) {
+		st := newStore(t)
+		create(t, st, "r-1", "a")
+		create(t, st, "r-2", "a")
+		require.NoError(t, st.Delete(ctx, "r-1"))
+
+		records, _, err := st.List(ctx, store.ListOptions{})
+		require.NoError(t, err)
+		all, _, err := st.List(store.WithDeleted(ctx), store.ListOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"r-2"}, ids(f, records))
+		assert.Equal(t, []string{"r-1", "r-2"}, ids(f, all))
+	})
+
+	t.Run("list invalid options", func(t *testing.T) {
+		st := newStore(t)
+
+		for _, opts := range []store.ListOptions{
+			{PageSize: -1},
+			{Sort: "unknown"},
+			{Filters: map[string]string{"unknown": "a"}},
+			{Filters: map[string]string{"created_at": "a"}},
+			{Cursor: "not a cursor"},
+		} {
+			_, _, err := st.List(ctx, opts)
+			assert.ErrorIs(t, err, store.ErrInvalid, "options %+v", opts)
+		}
+	})
+}
+
+func ids[T any](f fixture[T], records []*T) []string {
+	ret := make([]string, len(records))
+	for i, r := range records {
+		ret[i] = f.id(r)
+	}
+	return ret
+}
+
+// assertConflict fails the test unless err is a ConflictError with the given record ID
+func assertConflict(t *testing.T, err error, id string) {
+	t.Helper()
+	var conflict *store.ConflictError
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, id, conflict.ID)
+	}
+	assert.ErrorIs(t, err, store.ErrConflict)
+}
@@ -0,0 +1,223 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Stores are the stores taking part in the transactions of a Transactor
+type Stores struct {
+	Tx    store.Transactor
+	Users store.User
+	Plans store.Plan
+}
+
+// TestTransactor runs the conformance tests for store.Transactor implementations against the stores returned
+// by newStores, which must be empty
+func TestTransactor(t *testing.T, newStores func(t *testing.T) Stores) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	// prepare creates a user and a plan, to be changed in the transactions
+	prepare := func(t *testing.T) Stores {
+		st := newStores(t)
+		_, err := st.Users.Create(ctx, &model.User{ID: "user-1", Name: "John"})
+		require.NoError(t, err)
+		_, err = st.Plans.Create(ctx, &model.Plan{ID: "plan-1", Name: "Basic"})
+		require.NoError(t, err)
+		return st
+	}
+
+	// change makes one change of each kind, on both stores
+	change := func(ctx context.Context, st Stores) error {
+		if _, err := st.Users.Create(ctx, &model.User{ID: "user-2"}); err != nil {
+			return err
+		}
+		if _, err := st.Users.Update(ctx, &model.User{ID: "user-1", Name: "Jane"}); err != nil {
+			return err
+		}
+		if _, err := st.Plans.Create(ctx, &model.Plan{ID: "plan-2"}); err != nil {
+			return err
+		}
+		return st.Plans.Delete(ctx, "plan-1")
+	}
+
+	// unchanged fails the test unless the stores are as left by prepare
+	unchanged := func(t *testing.T, st Stores) {
+		user, err := st.Users.Get(ctx, "user-1")
+		require.NoError(t, err)
+		assert.Equal(t, "John", user.Name)
+		assert.EqualValues(t, 1, user.Version)
+		_, err = st.Users.Get(store.WithDeleted(ctx), "user-2")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+
+		plan, err := st.Plans.Get(ctx, "plan-1")
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, plan.Version)
+		_, err = st.Plans.Get(store.WithDeleted(ctx), "plan-2")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		st := prepare(t)
+
+		err := st.Tx.WithTx(ctx, func(ctx context.Context) error {
+			return change(ctx, st)
+		})
+
+		require.NoError(t, err)
+		user, err := st.Users.Get(ctx, "user-1")
+		require.NoError(t, err)
+		assert.Equal(t, "Jane", user.Name)
+		_, err = st.Users.Get(ctx, "user-2")
+		assert.NoError(t, err)
+		_, err = st.Plans.Get(ctx, "plan-1")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		_, err = st.Plans.Get(ctx, "plan-2")
+		assert.NoError(t, err)
+	})
+
+	t.Run("marks the context", func(t *testing.T) {
+		st := newStores(t)
+		assert.False(t, store.InTx(ctx))
+
+		err := st.Tx.WithTx(ctx, func(ctx context.Context) error {
+			assert.True(t, store.InTx(ctx))
+			return nil
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("runs the hooks once over", func(t *testing.T) {
+		st := newStores(t)
+		for _, want := range []error{nil, errBoom} {
+			var called []string
+
+			err := st.Tx.WithTx(ctx, func(ctx context.Context) error {
+				store.AfterTx(ctx, func() { called = append(called, "outer") })
+				_ = st.Tx.WithTx(ctx, func(ctx context.Context) error {
+					store.AfterTx(ctx, func() { called = append(called, "nested") })
+					return errBoom
+				})
+				assert.Empty(t, called, "the hooks wait for the outermost transaction")
+				return want
+			})
+
+			assert.ErrorIs(t, err, want)
+			assert.Equal(t, []string{"outer", "nested"}, called)
+		}
+
+		var called bool
+		store.AfterTx(ctx, func() { called = true })
+		assert.True(t, called, "outside transactions, the hooks run right away")
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		st := prepare(t)
+
+		err := st.Tx.WithTx(ctx, func(ctx context.Context) error {
+			if err := change(ctx, st); err != nil {
+				return err
+			}
+			return errBoom
+		})
+
+		assert.ErrorIs(t, err, errBoom)
+		unchanged(t, st)
+	})
+
+	t.Run("rollback on store error", func(t *testing.T) {
+		st := prepare(t)
+
+		err := st.Tx.WithTx(ctx, func(ctx context.Context) error {
+			if err := change(ctx, st); err != nil {
+				return err
+			}
+			_, err := st.Users.Create(ctx, &model.User{ID: "user-1"})
+			return err
+		})
+
+		assert.ErrorIs(t, err, store.ErrConflict)
+		unchanged(t, st)
+	})
+
+	t.Run("rollback on panic", func(t *testing.T) {
+		st := prepare(t)
+
+		assert.PanicsWithValue(t, "boom", func() {
+			_ = st.Tx.WithTx(ctx, func(ctx context.Context) error {
+				if err := change(ctx, st); err != nil {
+					return err
+				}
+				panic("boom")
+			})
+		})
+
+		unchanged(t, st)
+	})
+
+	t.Run("restore in a rolled back transaction", func(t *testing.T) {
+		st := prepare(t)
+		require.NoError(t, st.Plans.Delete(ctx, "plan-1"))
+
+		err := st.Tx.WithTx(ctx, func(ctx context.Context) error {
+			if _, err := st.Plans.Restore(ctx, "plan-1"); err != nil {
+				return err
+			}
+			return errBoom
+		})
+
+		assert.ErrorIs(t, err, errBoom)
+		_, err = st.Plans.Get(ctx, "plan-1")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("nested rollback", func(t *testing.T) {
+		st := prepare(t)
+
+		err := st.Tx.WithTx(ctx, func(ctx context.Context) error {
+			if _, err := st.Users.Create(ctx, &model.User{ID: "user-2"}); err != nil {
+				return err
+			}
+			err := st.Tx.WithTx(ctx, func(ctx context.Context) error {
+				if _, err := st.Plans.Create(ctx, &model.Plan{ID: "plan-2"}); err != nil {
+					return err
+				}
+				return errBoom
+			})
+			assert.ErrorIs(t, err, errBoom)
+			return nil
+		})
+
+		require.NoError(t, err)
+		_, err = st.Users.Get(ctx, "user-2")
+		assert.NoError(t, err, "the changes of the outer transaction were lost")
+		_, err = st.Plans.Get(ctx, "plan-2")
+		assert.ErrorIs(t, err, store.ErrNotFound, "the changes of the nested transaction were kept")
+	})
+
+	t.Run("nested commit, outer rollback", func(t *testing.T) {
+		st := prepare(t)
+
+		err := st.Tx.WithTx(ctx, func(ctx context.Context) error {
+			err := st.Tx.WithTx(ctx, func(ctx context.Context) error {
+				return change(ctx, st)
+			})
+			require.NoError(t, err)
+			return errBoom
+		})
+
+		assert.ErrorIs(t, err, errBoom)
+		unchanged(t, st)
+	})
+}
@@ -0,0 +1,65 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUser runs the conformance tests for store.User implementations against the stores returned by newStore,
+// which must be empty
+func TestUser(t *testing.T, newStore func(t *testing.T) store.User) {
+	run(t, func(t *testing.T) crud[model.User] { return newStore(t) }, fixture[model.User]{
+		fields: store.UserFields,
+		field:  "name",
+		newRecord: func(id, value string) *model.User {
+			return &model.User{ID: id, Name: value, Email: id + "@example.com"}
+		},
+		setValue:   func(u *model.User, value string) { u.Name = value },
+		version:    func(u *model.User) int64 { return u.Version },
+		setVersion: func(u *model.User, version int64) { u.Version = version },
+		deletedAt:  func(u *model.User) *time.Time { return u.DeletedAt },
+	})
+	t.Run("emails are unique", func(t *testing.T) {
+		ctx := context.Background()
+		st := newStore(t)
+		_, err := st.Create(ctx, &model.User{ID: "user-1", Email: "john@example.com"})
+		require.NoError(t, err)
+		_, err = st.Create(ctx, &model.User{ID: "user-2", Email: "jane@example.com"})
+		require.NoError(t, err)
+
+		_, errCreate := st.Create(ctx, &model.User{ID: "user-3", Email: "John@Example.com"})
+		_, errUpdate := st.Update(ctx, &model.User{ID: "user-2", Email: "JOHN@example.com"})
+		_, errUpdateSelf := st.Update(ctx, &model.User{ID: "user-1", Email: "John@example.com"})
+		_, errNoEmail := st.Create(ctx, &model.User{ID: "user-4"})
+		_, errNoEmailAgain := st.Create(ctx, &model.User{ID: "user-5"})
+
+		assertConflict(t, errCreate, "user-1")
+		assertConflict(t, errUpdate, "user-1")
+		assert.NoError(t, errUpdateSelf)
+		assert.NoError(t, errNoEmail)
+		assert.NoError(t, errNoEmailAgain)
+	})
+
+	t.Run("emails of deleted users can be reused", func(t *testing.T) {
+		ctx := context.Background()
+		st := newStore(t)
+		_, err := st.Create(ctx, &model.User{ID: "user-1", Email: "john@example.com"})
+		require.NoError(t, err)
+		require.NoError(t, st.Delete(ctx, "user-1"))
+
+		_, errCreate := st.Create(ctx, &model.User{ID: "user-2", Email: "john@example.com"})
+		_, errRestore := st.Restore(ctx, "user-1")
+
+		assert.NoError(t, errCreate)
+		assertConflict(t, errRestore, "user-2")
+	})
+}
@@ -5,14 +5,33 @@ package store
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
 )
 
+// Subscription persists model.Subscription records. A user has at most one subscription not deleted to each
+// plan, and clashes are reported with a ConflictError.
 type Subscription interface {
 	Get(ctx context.Context, id string) (*model.Subscription, error)
 	Create(ctx context.Context, user *model.Subscription) (*model.Subscription, error)
 	Update(ctx context.Context, user *model.Subscription) (*model.Subscription, error)
 	Delete(ctx context.Context, id string) error
-	List(ctx context.Context) ([]*model.Subscription, error)
+	Restore(ctx context.Context, id string) (*model.Subscription, error)
+	List(ctx context.Context, opts ListOptions) ([]*model.Subscription, string, error)
+}
+
+// SubscriptionFields are the fields subscriptions can be sorted and filtered by
+var SubscriptionFields = Fields[model.Subscription]{
+	"id":         func(s *model.Subscription) any { return s.ID },
+	"user_id":    func(s *model.Subscription) any { return s.UserID },
+	"plan_id":    func(s *model.Subscription) any { return s.PlanID },
+	"created_at": func(s *model.Subscription) any { return s.CreatedAt },
+	"updated_at": func(s *model.Subscription) any { return s.UpdatedAt },
+}
+
+// AlreadySubscribed is the error returned when the user is already subscribed to the plan by the subscription
+// with the given ID
+func AlreadySubscribed(id, userID, planID string) error {
+	return &ConflictError{Kind: "subscription", ID: id, Reason: fmt.Sprintf("already subscribes user %q to plan %q", userID, planID)}
 }
@@ -0,0 +1,77 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// Transactor runs groups of store operations atomically
+type Transactor interface {
+	// WithTx runs fn in a transaction, which is committed when fn returns nil and rolled back when it returns an
+	// error or panics. The operations made with the context passed to fn, on stores backed by the same
+	// database as the Transactor, take part in the transaction. A WithTx nested in another one only rolls back
+	// its own changes, leaving it to the outer fn to decide what happens with the rest.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Isolated reports whether the transactions of tx are isolated, their changes being seen by other callers only
+// once committed. Transactors tell so with an Isolated() bool method.
+func Isolated(tx Transactor) bool {
+	i, ok := tx.(interface{ Isolated() bool })
+	return ok && i.Isolated()
+}
+
+type inTxKey struct{}
+
+// txState is kept in the contexts of transactions, shared by the transactions nested in them
+type txState struct {
+	mu    sync.Mutex
+	hooks []func()
+}
+
+// MarkTx returns a context telling that operations made with it are part of a transaction, along with the
+// function to call once the transaction is over, which runs the functions registered with AfterTx. Transactor
+// implementations mark the contexts they pass to the functions they run. The functions registered in nested
+// transactions wait for the outermost one: the function returned for them does nothing.
+func MarkTx(ctx context.Context) (context.Context, func()) {
+	if _, ok := ctx.Value(inTxKey{}).(*txState); ok {
+		return ctx, func() {}
+	}
+	st := &txState{}
+	return context.WithValue(ctx, inTxKey{}, st), st.end
+}
+
+func (st *txState) end() {
+	st.mu.Lock()
+	hooks := st.hooks
+	st.hooks = nil
+	st.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// InTx reports whether operations made with ctx are part of a transaction, whose changes may still be
+// rolled back
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(inTxKey{}).(*txState)
+	return ok
+}
+
+// AfterTx calls fn once the transaction of ctx is over, whether committed or rolled back, or right away when
+// ctx isn't part of a transaction
+func AfterTx(ctx context.Context, fn func()) {
+	st, ok := ctx.Value(inTxKey{}).(*txState)
+	if !ok {
+		fn()
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.hooks = append(st.hooks, fn)
+}
@@ -5,14 +5,32 @@ package store
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
 )
 
+// User persists model.User records. Emails are unique among the users not deleted, regardless of case, and
+// clashes are reported with a ConflictError.
 type User interface {
 	Get(ctx context.Context, id string) (*model.User, error)
 	Create(ctx context.Context, user *model.User) (*model.User, error)
 	Update(ctx context.Context, user *model.User) (*model.User, error)
 	Delete(ctx context.Context, id string) error
-	List(ctx context.Context) ([]*model.User, error)
+	Restore(ctx context.Context, id string) (*model.User, error)
+	List(ctx context.Context, opts ListOptions) ([]*model.User, string, error)
+}
+
+// UserFields are the fields users can be sorted and filtered by
+var UserFields = Fields[model.User]{
+	"id":         func(u *model.User) any { return u.ID },
+	"name":       func(u *model.User) any { return u.Name },
+	"email":      func(u *model.User) any { return u.Email },
+	"created_at": func(u *model.User) any { return u.CreatedAt },
+	"updated_at": func(u *model.User) any { return u.UpdatedAt },
+}
+
+// EmailInUse is the error returned when email is already used by the user with the given ID
+func EmailInUse(id, email string) error {
+	return &ConflictError{Kind: "user", ID: id, Reason: fmt.Sprintf("already uses email %q", email)}
 }
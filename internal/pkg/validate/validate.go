@@ -0,0 +1,168 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+// Package validate checks records against the rules declared in the validate tags of their fields, like in
+//
+//	Name string `json:"name" validate:"required,max=100"`
+//
+// The rules are:
+//   - required: the field isn't empty, or zero
+//   - min=n and max=n: the length of strings, in characters, or the value of numbers is within the limit
+//   - gt=n: numbers are greater than n
+//   - email: strings are a plain email address, like john@example.com; empty strings are left to required
+//
+// Fields are named after their JSON names in the violations.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+)
+
+// field is a struct field with validation rules
+type field struct {
+	index int
+	name  string
+	rules []rule
+}
+
+// rule returns why the value violates it, or an empty string when it doesn't
+type rule func(v reflect.Value) string
+
+// fields caches the fields with rules of each struct type
+var fields sync.Map // of reflect.Type to []field
+
+// Record checks record, a pointer to a struct, against the rules of its fields, returning a
+// store.ValidationError with all the violations found
+func Record(record any) error {
+	v := reflect.Indirect(reflect.ValueOf(record))
+
+	var violations []store.FieldError
+	for _, f := range fieldsOf(v.Type()) {
+		for _, rule := range f.rules {
+			if msg := rule(v.Field(f.index)); msg != "" {
+				violations = append(violations, store.FieldError{Field: f.name, Message: msg})
+				// the first violation of a field is enough
+				break
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return &store.ValidationError{Fields: violations}
+	}
+	return nil
+}
+
+func fieldsOf(t reflect.Type) []field {
+	if cached, ok := fields.Load(t); ok {
+		return cached.([]field)
+	}
+
+	var ret []field
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "" {
+			name = sf.Name
+		}
+		f := field{index: i, name: name}
+		for _, spec := range strings.Split(tag, ",") {
+			f.rules = append(f.rules, parseRule(t, sf, spec))
+		}
+		ret = append(ret, f)
+	}
+
+	fields.Store(t, ret)
+	return ret
+}
+
+// parseRule returns the rule described by spec. Invalid specs are programming errors, causing a panic.
+func parseRule(t reflect.Type, sf reflect.StructField, spec string) rule {
+	name, arg, _ := strings.Cut(spec, "=")
+	invalid := func(reason string) {
+		panic(fmt.Sprintf("validate: invalid rule %q for %s.%s: %s", spec, t.Name(), sf.Name, reason))
+	}
+
+	switch name {
+	case "required":
+		return func(v reflect.Value) string {
+			if v.IsZero() {
+				return "is required"
+			}
+			return ""
+		}
+
+	case "email":
+		if sf.Type.Kind() != reflect.String {
+			invalid("not a string")
+		}
+		return func(v reflect.Value) string {
+			s := v.String()
+			if s == "" {
+				return ""
+			}
+			if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+				return "must be a valid email address"
+			}
+			return ""
+		}
+
+	case "min", "max", "gt":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			invalid("the limit isn't a number")
+		}
+		size, unit := sizeOf(sf.Type)
+		if size == nil {
+			invalid("neither a string nor a number")
+		}
+		if name == "gt" && unit != "" {
+			invalid("gt applies to numbers only")
+		}
+		return func(v reflect.Value) string {
+			n := size(v)
+			switch {
+			case name == "min" && n < limit:
+				return strings.TrimSpace(fmt.Sprintf("must be at least %s %s", arg, unit))
+			case name == "max" && n > limit:
+				return strings.TrimSpace(fmt.Sprintf("must be at most %s %s", arg, unit))
+			case name == "gt" && n <= limit:
+				return fmt.Sprintf("must be greater than %s", arg)
+			}
+			return ""
+		}
+
+	default:
+		invalid("unknown rule")
+		return nil
+	}
+}
+
+// sizeOf returns the function measuring values of type t, their length for strings and their value for
+// numbers, along with the unit to report limits in. It returns nil for other types.
+func sizeOf(t reflect.Type) (func(reflect.Value) float64, string) {
+	switch t.Kind() {
+	case reflect.String:
+		return func(v reflect.Value) float64 { return float64(utf8.RuneCountInString(v.String())) }, "characters long"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value) float64 { return float64(v.Int()) }, ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value) float64 { return float64(v.Uint()) }, ""
+	case reflect.Float32, reflect.Float64:
+		return func(v reflect.Value) float64 { return v.Float() }, ""
+	default:
+		return nil, ""
+	}
+}
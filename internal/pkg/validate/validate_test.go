@@ -0,0 +1,86 @@
+// Copyright Dose de Telemetria GmbH
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/model"
+	"github.com/dosedetelemetria/projeto-otel-na-pratica/internal/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		record   any
+		expected []store.FieldError
+	}{
+		{
+			name:   "valid user",
+			record: &model.User{Name: "John", Email: "john@example.com"},
+		},
+		{
+			name:   "all the violations of a user",
+			record: &model.User{Email: "John <john@example.com>"},
+			expected: []store.FieldError{
+				{Field: "name", Message: "is required"},
+				{Field: "email", Message: "must be a valid email address"},
+			},
+		},
+		{
+			name:     "only the first violation of a field",
+			record:   &model.User{Name: "John"},
+			expected: []store.FieldError{{Field: "email", Message: "is required"}},
+		},
+		{
+			name:   "lengths in characters",
+			record: &model.Plan{Name: strings.Repeat("é", 100), Description: strings.Repeat("a", 1001), Price: -1},
+			expected: []store.FieldError{
+				{Field: "price", Message: "must be at least 0"},
+				{Field: "description", Message: "must be at most 1000 characters long"},
+			},
+		},
+		{
+			name:   "numbers",
+			record: &model.Payment{SubscriptionID: "sub-1"},
+			expected: []store.FieldError{
+				{Field: "amount", Message: "must be greater than 0"},
+			},
+		},
+		{
+			name:   "subscription",
+			record: &model.Subscription{UserID: strings.Repeat("1", 65)},
+			expected: []store.FieldError{
+				{Field: "user_id", Message: "must be at most 64 characters long"},
+				{Field: "plan_id", Message: "is required"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// test
+			err := Record(tc.record)
+
+			// verify
+			if tc.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, store.ErrInvalid)
+			var invalid *store.ValidationError
+			require.ErrorAs(t, err, &invalid)
+			assert.Equal(t, tc.expected, invalid.Fields)
+		})
+	}
+}
+
+func TestRecord_InvalidRule(t *testing.T) {
+	type record struct {
+		Name string `validate:"gt=1"`
+	}
+
+	assert.Panics(t, func() { _ = Record(&record{}) })
+}